@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	craneProtos "scow-crane-adapter/gen/crane"
+	protos "scow-crane-adapter/gen/go"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestCreateAccount(t *testing.T) {
+	fake, conn := newTestServer(t)
+	client := protos.NewAccountServiceClient(conn)
+	owner := currentUser(t)
+
+	_, err := client.CreateAccount(context.Background(), &protos.CreateAccountRequest{AccountName: "a_admin", OwnerUserId: owner})
+	if err != nil {
+		t.Fatalf("CreateAccount failed: %v", err)
+	}
+
+	account := fake.Account("a_admin")
+	if assert.NotNil(t, account) {
+		assert.Equal(t, []string{"CPU"}, account.AllowedPartitions)
+		assert.Equal(t, []string{"normal", "high"}, account.AllowedQosList)
+		assert.Equal(t, "normal", account.DefaultQos)
+	}
+	user := fake.User(owner, "a_admin")
+	if assert.NotNil(t, user) {
+		assert.Equal(t, craneProtos.UserInfo_Operator, user.AdminLevel)
+	}
+}
+
+func TestListAccounts(t *testing.T) {
+	_, conn := newTestServer(t)
+	client := protos.NewAccountServiceClient(conn)
+	owner := currentUser(t)
+	for _, name := range []string{"a_admin", "b_admin"} {
+		if _, err := client.CreateAccount(context.Background(), &protos.CreateAccountRequest{AccountName: name, OwnerUserId: owner}); err != nil {
+			t.Fatalf("CreateAccount failed: %v", err)
+		}
+	}
+
+	res, err := client.ListAccounts(context.Background(), &protos.ListAccountsRequest{UserId: owner})
+	if err != nil {
+		t.Fatalf("ListAccounts failed: %v", err)
+	}
+
+	assert.Equal(t, []string{"a_admin", "b_admin"}, res.Accounts)
+}
+
+func TestBlockAndUnblockAccount(t *testing.T) {
+	fake, conn := newTestServer(t)
+	client := protos.NewAccountServiceClient(conn)
+	if _, err := client.CreateAccount(context.Background(), &protos.CreateAccountRequest{AccountName: "a_admin", OwnerUserId: currentUser(t)}); err != nil {
+		t.Fatalf("CreateAccount failed: %v", err)
+	}
+
+	_, err := client.BlockAccount(context.Background(), &protos.BlockAccountRequest{AccountName: "a_admin"})
+	if err != nil {
+		t.Fatalf("BlockAccount failed: %v", err)
+	}
+	assert.True(t, fake.Account("a_admin").Blocked)
+	res, err := client.QueryAccountBlockStatus(context.Background(), &protos.QueryAccountBlockStatusRequest{AccountName: "a_admin"})
+	if err != nil {
+		t.Fatalf("QueryAccountBlockStatus failed: %v", err)
+	}
+	assert.True(t, res.Blocked)
+
+	_, err = client.UnblockAccount(context.Background(), &protos.UnblockAccountRequest{AccountName: "a_admin"})
+	if err != nil {
+		t.Fatalf("UnblockAccount failed: %v", err)
+	}
+	assert.False(t, fake.Account("a_admin").Blocked)
+	res, err = client.QueryAccountBlockStatus(context.Background(), &protos.QueryAccountBlockStatusRequest{AccountName: "a_admin"})
+	if err != nil {
+		t.Fatalf("QueryAccountBlockStatus failed: %v", err)
+	}
+	assert.False(t, res.Blocked)
+}
+
+func TestQueryAccountBlockStatusNotFound(t *testing.T) {
+	_, conn := newTestServer(t)
+	client := protos.NewAccountServiceClient(conn)
+
+	_, err := client.QueryAccountBlockStatus(context.Background(), &protos.QueryAccountBlockStatusRequest{AccountName: "not_exists"})
+
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.Equal(t, "ACCOUNT_NOT_FOUND", errorReason(err))
+}
+
+func TestGetAllAccountsWithUsers(t *testing.T) {
+	_, conn := newTestServer(t)
+	client := protos.NewAccountServiceClient(conn)
+	owner := currentUser(t)
+	if _, err := client.CreateAccount(context.Background(), &protos.CreateAccountRequest{AccountName: "a_admin", OwnerUserId: owner}); err != nil {
+		t.Fatalf("CreateAccount failed: %v", err)
+	}
+	if _, err := client.CreateAccount(context.Background(), &protos.CreateAccountRequest{AccountName: "b_admin", OwnerUserId: owner}); err != nil {
+		t.Fatalf("CreateAccount failed: %v", err)
+	}
+	if _, err := client.BlockAccount(context.Background(), &protos.BlockAccountRequest{AccountName: "b_admin"}); err != nil {
+		t.Fatalf("BlockAccount failed: %v", err)
+	}
+
+	res, err := client.GetAllAccountsWithUsers(context.Background(), &protos.GetAllAccountsWithUsersRequest{})
+	if err != nil {
+		t.Fatalf("GetAllAccountsWithUsers failed: %v", err)
+	}
+
+	if assert.Len(t, res.Accounts, 2) {
+		assert.Equal(t, "a_admin", res.Accounts[0].AccountName)
+		assert.False(t, res.Accounts[0].Blocked)
+		assert.Equal(t, "b_admin", res.Accounts[1].AccountName)
+		assert.True(t, res.Accounts[1].Blocked)
+		if assert.Len(t, res.Accounts[0].Users, 1) {
+			assert.Equal(t, owner, res.Accounts[0].Users[0].UserId)
+		}
+	}
+}
+
+func TestCreateAccountOwnerNotFound(t *testing.T) {
+	fake, conn := newTestServer(t)
+	client := protos.NewAccountServiceClient(conn)
+
+	_, err := client.CreateAccount(context.Background(), &protos.CreateAccountRequest{AccountName: "a_admin", OwnerUserId: "scow_no_such_user"})
+
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.Equal(t, "USER_NOT_FOUND", errorReason(err))
+	assert.Nil(t, fake.Account("a_admin"))
+}
+
+func TestCreateAccountRollsBackWhenAddUserFails(t *testing.T) {
+	fake, conn := newTestServer(t)
+	client := protos.NewAccountServiceClient(conn)
+	fake.SetError("AddUser", errors.New("connection refused"))
+
+	_, err := client.CreateAccount(context.Background(), &protos.CreateAccountRequest{AccountName: "a_admin", OwnerUserId: currentUser(t)})
+
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Nil(t, fake.Account("a_admin"))
+
+	// 回滚后可以重试
+	fake.SetError("AddUser", nil)
+	_, err = client.CreateAccount(context.Background(), &protos.CreateAccountRequest{AccountName: "a_admin", OwnerUserId: currentUser(t)})
+	assert.NoError(t, err)
+}
+
+func TestCreateAccountRollbackFails(t *testing.T) {
+	fake, conn := newTestServer(t)
+	client := protos.NewAccountServiceClient(conn)
+	fake.SetError("AddUser", errors.New("connection refused"))
+	fake.SetError("DeleteEntity", errors.New("connection refused"))
+
+	_, err := client.CreateAccount(context.Background(), &protos.CreateAccountRequest{AccountName: "a_admin", OwnerUserId: currentUser(t)})
+
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Equal(t, "CRANE_CALL_FAILED", errorReason(err))
+	assert.Contains(t, status.Convert(err).Message(), "rollback of account a_admin failed")
+}
+
+func TestCreateAccountCompletesOwnerAfterFailedRollback(t *testing.T) {
+	fake, conn := newTestServer(t)
+	client := protos.NewAccountServiceClient(conn)
+	owner := currentUser(t)
+	fake.SetError("AddUser", errors.New("connection refused"))
+	fake.SetError("DeleteEntity", errors.New("connection refused"))
+
+	_, err := client.CreateAccount(context.Background(), &protos.CreateAccountRequest{AccountName: "a_admin", OwnerUserId: owner})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	// 回滚失败，账户中没有拥有者
+	assert.NotNil(t, fake.Account("a_admin"))
+	assert.Nil(t, fake.User(owner, "a_admin"))
+
+	// 重试时完成添加拥有者
+	fake.SetError("AddUser", nil)
+	fake.SetError("DeleteEntity", nil)
+	_, err = client.CreateAccount(context.Background(), &protos.CreateAccountRequest{AccountName: "a_admin", OwnerUserId: owner})
+	assert.NoError(t, err)
+	user := fake.User(owner, "a_admin")
+	if assert.NotNil(t, user) {
+		assert.Equal(t, craneProtos.UserInfo_Operator, user.AdminLevel)
+	}
+}
+
+func TestCreateAccountIsIdempotent(t *testing.T) {
+	fake, conn := newTestServer(t)
+	client := protos.NewAccountServiceClient(conn)
+	owner := currentUser(t)
+
+	for i := 0; i < 2; i++ {
+		_, err := client.CreateAccount(context.Background(), &protos.CreateAccountRequest{AccountName: "a_admin", OwnerUserId: owner})
+		assert.NoError(t, err)
+	}
+	assert.NotNil(t, fake.Account("a_admin"))
+	assert.NotNil(t, fake.User(owner, "a_admin"))
+}
+
+func TestCreateAccountAlreadyExistsWithOtherOwner(t *testing.T) {
+	fake, conn := newTestServer(t)
+	client := protos.NewAccountServiceClient(conn)
+	addTestAccount(t, fake, "a_admin")
+	response, err := fake.AddUser(context.Background(), &craneProtos.AddUserRequest{User: &craneProtos.UserInfo{Name: "other_owner", Account: "a_admin"}})
+	if err != nil || !response.GetOk() {
+		t.Fatalf("AddUser failed: %v %s", err, response.GetReason())
+	}
+
+	_, err = client.CreateAccount(context.Background(), &protos.CreateAccountRequest{AccountName: "a_admin", OwnerUserId: currentUser(t)})
+
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+	assert.Equal(t, "ACCOUNT_ALREADY_EXISTS", errorReason(err))
+}
+
+func TestBlockAndUnblockAccountAreIdempotent(t *testing.T) {
+	fake, conn := newTestServer(t)
+	client := protos.NewAccountServiceClient(conn)
+	addTestAccount(t, fake, "a_admin")
+
+	for i := 0; i < 2; i++ {
+		_, err := client.BlockAccount(context.Background(), &protos.BlockAccountRequest{AccountName: "a_admin"})
+		assert.NoError(t, err)
+		assert.True(t, fake.Account("a_admin").Blocked)
+	}
+	for i := 0; i < 2; i++ {
+		_, err := client.UnblockAccount(context.Background(), &protos.UnblockAccountRequest{AccountName: "a_admin"})
+		assert.NoError(t, err)
+		assert.False(t, fake.Account("a_admin").Blocked)
+	}
+}
+
+func TestBlockAccountNotFound(t *testing.T) {
+	_, conn := newTestServer(t)
+	client := protos.NewAccountServiceClient(conn)
+
+	_, err := client.BlockAccount(context.Background(), &protos.BlockAccountRequest{AccountName: "a_admin"})
+
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.Equal(t, "ACCOUNT_NOT_FOUND", errorReason(err))
+}
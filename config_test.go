@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	craneProtos "scow-crane-adapter/gen/crane"
+	protos "scow-crane-adapter/gen/go"
+	"scow-crane-adapter/utils"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestGetClusterConfig(t *testing.T) {
+	_, conn := newTestServer(t)
+	client := protos.NewConfigServiceClient(conn)
+
+	res, err := client.GetClusterConfig(context.Background(), &protos.GetClusterConfigRequest{})
+	if err != nil {
+		t.Fatalf("GetClusterConfig failed: %v", err)
+	}
+
+	assert.Equal(t, "Crane", res.SchedulerName)
+	assert.Len(t, res.Partitions, 1)
+	assert.Equal(t, "CPU", res.Partitions[0].Name)
+	assert.Equal(t, uint32(128), res.Partitions[0].Cores)
+	assert.Equal(t, uint32(4), res.Partitions[0].Nodes)
+	assert.Equal(t, uint64(512*1024), res.Partitions[0].MemMb)
+	assert.Equal(t, []string{"normal", "high"}, res.Partitions[0].Qos)
+}
+
+func TestGetClusterConfigCraneUnavailable(t *testing.T) {
+	fake, conn := newTestServer(t)
+	client := protos.NewConfigServiceClient(conn)
+	fake.SetError("QueryPartitionInfo", errors.New("connection refused"))
+
+	_, err := client.GetClusterConfig(context.Background(), &protos.GetClusterConfigRequest{})
+
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+}
+
+func TestGetClusterConfigQosUnavailable(t *testing.T) {
+	fake, conn := newTestServer(t)
+	client := protos.NewConfigServiceClient(conn)
+	fake.SetError("QueryEntityInfo", errors.New("connection refused"))
+
+	_, err := client.GetClusterConfig(context.Background(), &protos.GetClusterConfigRequest{})
+
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Equal(t, "CRANE_CALL_FAILED", errorReason(err))
+}
+
+func TestConfigPartitionNotFound(t *testing.T) {
+	_, conn := newTestServer(t)
+	client := protos.NewConfigServiceClient(conn)
+	// 配置文件中的分区在Crane中不存在
+	config.Partitions = append(config.Partitions, utils.Partition{Name: "GPU"})
+
+	_, err := client.GetClusterConfig(context.Background(), &protos.GetClusterConfigRequest{})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.Equal(t, "PARTITION_NOT_FOUND", errorReason(err))
+
+	_, err = client.GetAvailablePartitions(context.Background(), &protos.GetAvailablePartitionsRequest{AccountName: "a_admin", UserId: currentUser(t)})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.Equal(t, "PARTITION_NOT_FOUND", errorReason(err))
+
+	_, err = client.GetClusterInfo(context.Background(), &protos.GetClusterInfoRequest{})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.Equal(t, "PARTITION_NOT_FOUND", errorReason(err))
+}
+
+func TestGetAvailablePartitions(t *testing.T) {
+	_, conn := newTestServer(t)
+	client := protos.NewConfigServiceClient(conn)
+
+	res, err := client.GetAvailablePartitions(context.Background(), &protos.GetAvailablePartitionsRequest{AccountName: "a_admin", UserId: currentUser(t)})
+	if err != nil {
+		t.Fatalf("GetAvailablePartitions failed: %v", err)
+	}
+
+	assert.Len(t, res.Partitions, 1)
+	assert.Equal(t, "CPU", res.Partitions[0].Name)
+}
+
+func TestGetClusterInfo(t *testing.T) {
+	fake, conn := newTestServer(t)
+	client := protos.NewConfigServiceClient(conn)
+	fake.AddCraned("crane01", craneProtos.CranedState_CRANE_ALLOC, "CPU")
+	fake.AddCraned("crane02", craneProtos.CranedState_CRANE_MIX, "CPU")
+	fake.AddCraned("crane03", craneProtos.CranedState_CRANE_IDLE, "CPU")
+	fake.AddCraned("crane04", craneProtos.CranedState_CRANE_DOWN, "CPU")
+	addTestTask(fake, "demo", "a_admin", craneProtos.TaskStatus_Running)
+	addTestTask(fake, "demo", "a_admin", craneProtos.TaskStatus_Pending)
+	addTestTask(fake, "demo", "a_admin", craneProtos.TaskStatus_Pending)
+	addTestTask(fake, "demo", "a_admin", craneProtos.TaskStatus_Completed)
+
+	res, err := client.GetClusterInfo(context.Background(), &protos.GetClusterInfoRequest{})
+	if err != nil {
+		t.Fatalf("GetClusterInfo failed: %v", err)
+	}
+
+	assert.Equal(t, "test", res.ClusterName)
+	if assert.Len(t, res.Partitions, 1) {
+		partition := res.Partitions[0]
+		assert.Equal(t, "CPU", partition.PartitionName)
+		assert.Equal(t, uint32(4), partition.NodeCount)
+		assert.Equal(t, uint32(2), partition.RunningNodeCount)
+		assert.Equal(t, uint32(1), partition.IdleNodeCount)
+		assert.Equal(t, uint32(1), partition.NotAvailableNodeCount)
+		assert.Equal(t, uint32(1), partition.RunningJobCount)
+		assert.Equal(t, uint32(2), partition.PendingJobCount)
+		assert.Equal(t, uint32(3), partition.JobCount)
+		assert.Equal(t, uint32(50), partition.UsageRatePercentage)
+		assert.Equal(t, protos.PartitionInfo_AVAILABLE, partition.PartitionStatus)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	craneProtos "scow-crane-adapter/gen/crane"
+	protos "scow-crane-adapter/gen/go"
+	"scow-crane-adapter/utils"
+
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// 定期检查的间隔，CraneCtld连接状态变化时会立即检查
+const healthCheckInterval = 30 * time.Second
+
+// 依赖CraneCtld的服务，CraneCtld不可用时状态为NOT_SERVING
+var craneServices = []string{
+	protos.JobService_ServiceDesc.ServiceName,
+	protos.AccountService_ServiceDesc.ServiceName,
+	protos.ConfigService_ServiceDesc.ServiceName,
+	protos.UserService_ServiceDesc.ServiceName,
+}
+
+// 根据CraneCtld的可用性更新grpc.health.v1中各个服务的状态
+type healthChecker struct {
+	server        *health.Server
+	stubCraneCtld utils.CraneCtldClient
+	trigger       chan struct{}
+	lastErr       error
+}
+
+func newHealthChecker(stubCraneCtld utils.CraneCtldClient) *healthChecker {
+	h := &healthChecker{
+		server:        health.NewServer(),
+		stubCraneCtld: stubCraneCtld,
+		trigger:       make(chan struct{}, 1),
+	}
+	// 不依赖CraneCtld的服务始终可用，其余服务在第一次检查通过前为NOT_SERVING
+	h.server.SetServingStatus(protos.VersionService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	h.server.SetServingStatus(protos.AppService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	h.setCraneServicesStatus(healthpb.HealthCheckResponse_NOT_SERVING)
+	return h
+}
+
+func (h *healthChecker) setCraneServicesStatus(servingStatus healthpb.HealthCheckResponse_ServingStatus) {
+	for _, service := range craneServices {
+		h.server.SetServingStatus(service, servingStatus)
+	}
+	// 空服务名表示适配器整体的状态
+	h.server.SetServingStatus("", servingStatus)
+}
+
+// 检查CraneCtld连接是否可用，以及QOS和分区信息能否正常查询
+func checkCraneCtld(ctx context.Context, stubCraneCtld utils.CraneCtldClient) error {
+	if conn, ok := stubCraneCtld.(interface{ State() connectivity.State }); ok {
+		if state := conn.State(); state == connectivity.TransientFailure || state == connectivity.Shutdown {
+			return fmt.Errorf("CraneCtld connection is %v", state)
+		}
+	}
+	qosList, err := utils.GetQos(ctx, stubCraneCtld)
+	if err != nil {
+		return fmt.Errorf("query qos: %w", err)
+	}
+	if len(utils.RemoveValue(qosList, "UNLIMITED")) == 0 {
+		return fmt.Errorf("no qos found in crane")
+	}
+	for _, partition := range config.Partitions {
+		response, err := stubCraneCtld.QueryPartitionInfo(ctx, &craneProtos.QueryPartitionInfoRequest{PartitionName: partition.Name})
+		if err != nil {
+			return fmt.Errorf("query partition %s: %w", partition.Name, err)
+		}
+		if len(response.GetPartitionInfo()) == 0 {
+			return fmt.Errorf("partition %s not found in crane", partition.Name)
+		}
+	}
+	return nil
+}
+
+// 执行一次检查并更新服务状态
+func (h *healthChecker) check(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	err := checkCraneCtld(ctx, h.stubCraneCtld)
+	if err != nil {
+		if h.lastErr == nil || h.lastErr.Error() != err.Error() {
+			logger.Warnf("Health check failed: %v", err)
+		}
+		h.setCraneServicesStatus(healthpb.HealthCheckResponse_NOT_SERVING)
+	} else {
+		if h.lastErr != nil {
+			logger.Infof("Health check recovered")
+		}
+		h.setCraneServicesStatus(healthpb.HealthCheckResponse_SERVING)
+	}
+	h.lastErr = err
+}
+
+// 请求立即检查一次，不会阻塞
+func (h *healthChecker) checkNow() {
+	select {
+	case h.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// 定期检查直到ctx结束
+func (h *healthChecker) run(ctx context.Context) {
+	ticker := time.NewTicker(healthCheckInterval)
+	defer ticker.Stop()
+	for {
+		h.check(ctx)
+		select {
+		case <-ctx.Done():
+			h.server.Shutdown()
+			return
+		case <-ticker.C:
+		case <-h.trigger:
+		}
+	}
+}
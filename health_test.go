@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	protos "scow-crane-adapter/gen/go"
+	"scow-crane-adapter/utils"
+
+	"github.com/stretchr/testify/assert"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func servingStatus(t *testing.T, h *healthChecker, service string) healthpb.HealthCheckResponse_ServingStatus {
+	t.Helper()
+	res, err := h.server.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+	if err != nil {
+		t.Fatalf("health check %q failed: %v", service, err)
+	}
+	return res.Status
+}
+
+func TestHealthCheck(t *testing.T) {
+	fake, _ := newTestServer(t)
+	h := newHealthChecker(fake)
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, servingStatus(t, h, ""))
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, servingStatus(t, h, protos.VersionService_ServiceDesc.ServiceName))
+
+	h.check(context.Background())
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, servingStatus(t, h, ""))
+	for _, service := range craneServices {
+		assert.Equal(t, healthpb.HealthCheckResponse_SERVING, servingStatus(t, h, service))
+	}
+
+	// QOS查询失败时依赖CraneCtld的服务不可用
+	fake.SetError("QueryEntityInfo", errors.New("connection refused"))
+	h.check(context.Background())
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, servingStatus(t, h, ""))
+	for _, service := range craneServices {
+		assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, servingStatus(t, h, service))
+	}
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, servingStatus(t, h, protos.VersionService_ServiceDesc.ServiceName))
+
+	// 恢复后重新变为SERVING
+	fake.SetError("QueryEntityInfo", nil)
+	h.check(context.Background())
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, servingStatus(t, h, ""))
+}
+
+func TestHealthCheckPartitionNotFound(t *testing.T) {
+	fake, _ := newTestServer(t)
+	config.Partitions = append(config.Partitions, utils.Partition{Name: "GPU"})
+	h := newHealthChecker(fake)
+
+	h.check(context.Background())
+
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, servingStatus(t, h, protos.JobService_ServiceDesc.ServiceName))
+}
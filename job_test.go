@@ -0,0 +1,777 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os/user"
+	"path/filepath"
+	"testing"
+	"time"
+
+	craneProtos "scow-crane-adapter/gen/crane"
+	protos "scow-crane-adapter/gen/go"
+	"scow-crane-adapter/tests/fakectld"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// 在fake CraneCtld中添加一个指定状态的作业
+func addTestTask(fake *fakectld.FakeCraneCtld, username string, account string, status craneProtos.TaskStatus) uint32 {
+	now := time.Now()
+	task := &craneProtos.TaskInfo{
+		Type:      craneProtos.TaskType_Batch,
+		Name:      "test",
+		Partition: "CPU",
+		Account:   account,
+		Username:  username,
+		Qos:       "normal",
+		NodeNum:   1,
+		Cwd:       "/tmp",
+		TimeLimit: durationpb.New(time.Hour),
+		Status:    status,
+		// 提交时间早于开始时间，用于区分两者
+		SubmitTime: timestamppb.New(now.Add(-2 * time.Hour)),
+	}
+	if status != craneProtos.TaskStatus_Pending {
+		task.StartTime = timestamppb.New(now.Add(-time.Hour))
+		task.AllocCpu = 2
+		task.CranedList = "crane01"
+		task.ResView = &craneProtos.ResourceView{
+			AllocatableRes: &craneProtos.AllocatableResource{CpuCoreLimit: 2, MemoryLimitBytes: 4096 * 1024 * 1024},
+			DeviceMap: &craneProtos.DeviceMap{NameTypeMap: map[string]*craneProtos.TypeCountMap{
+				"gpu": {TypeCountMap: map[string]uint64{"a100": 2}, Total: 2},
+			}},
+		}
+	}
+	if status != craneProtos.TaskStatus_Pending && status != craneProtos.TaskStatus_Running {
+		task.EndTime = timestamppb.New(now.Add(-time.Minute))
+	}
+	return fake.AddTask(task)
+}
+
+func TestSubmitJob(t *testing.T) {
+	fake, conn := newTestServer(t)
+	client := protos.NewJobServiceClient(conn)
+	addTestAccount(t, fake, "a_admin")
+
+	qos := "normal"
+	timeLimitMinutes := uint32(90)
+	memoryMb := uint64(200)
+	stdout := "crane-%j.out"
+	req := &protos.SubmitJobRequest{
+		UserId:           currentUser(t),
+		JobName:          "test",
+		Account:          "a_admin",
+		Partition:        "CPU",
+		Qos:              &qos,
+		NodeCount:        2,
+		MemoryMb:         &memoryMb,
+		CoreCount:        4,
+		TimeLimitMinutes: &timeLimitMinutes,
+		Script:           "sleep 100",
+		WorkingDirectory: "/tmp/test",
+		Stdout:           &stdout,
+	}
+	res, err := client.SubmitJob(context.Background(), req)
+	if err != nil {
+		t.Fatalf("SubmitJob failed: %v", err)
+	}
+
+	task := fake.SubmittedTask(res.JobId)
+	if assert.NotNil(t, task) {
+		assert.Equal(t, "test", task.Name)
+		assert.Equal(t, "a_admin", task.Account)
+		assert.Equal(t, "CPU", task.PartitionName)
+		assert.Equal(t, "normal", task.Qos)
+		assert.Equal(t, uint32(2), task.NodeNum)
+		assert.Equal(t, float64(4), task.CpusPerTask)
+		assert.Equal(t, int64(90*60), task.TimeLimit.Seconds)
+		assert.Equal(t, uint64(200*1024*1024), task.Resources.AllocatableRes.MemoryLimitBytes)
+		assert.Equal(t, "/tmp/test", task.Cwd)
+		assert.Equal(t, "/tmp/test/crane-%j.out", task.GetBatchMeta().OutputFilePattern)
+		assert.Equal(t, res.GeneratedScript, task.GetBatchMeta().ShScript)
+	}
+	assert.Contains(t, res.GeneratedScript, "#CBATCH --time 1:30:00\n")
+	assert.Contains(t, res.GeneratedScript, "sleep 100")
+}
+
+func TestSubmitJobRelativePaths(t *testing.T) {
+	fake, conn := newTestServer(t)
+	client := protos.NewJobServiceClient(conn)
+	addTestAccount(t, fake, "a_admin")
+	u, err := user.Current()
+	if err != nil {
+		t.Fatalf("lookup current user failed: %v", err)
+	}
+
+	stdout := "logs/%j.out"
+	req := &protos.SubmitJobRequest{
+		UserId:           u.Username,
+		JobName:          "test",
+		Account:          "a_admin",
+		Partition:        "CPU",
+		NodeCount:        1,
+		CoreCount:        1,
+		Script:           "sleep 100",
+		WorkingDirectory: "work",
+		Stdout:           &stdout,
+	}
+	res, err := client.SubmitJob(context.Background(), req)
+	if err != nil {
+		t.Fatalf("SubmitJob failed: %v", err)
+	}
+
+	// 工作目录相对于用户家目录，输出文件相对于工作目录
+	task := fake.SubmittedTask(res.JobId)
+	if assert.NotNil(t, task) {
+		assert.Equal(t, u.HomeDir+"/work", task.Cwd)
+		assert.Equal(t, filepath.Join(u.HomeDir, "work", "logs/%j.out"), task.GetBatchMeta().OutputFilePattern)
+	}
+
+	// 绝对路径保持不变
+	stdout = "/data/%j.out"
+	res, err = client.SubmitJob(context.Background(), req)
+	if err != nil {
+		t.Fatalf("SubmitJob failed: %v", err)
+	}
+	if task := fake.SubmittedTask(res.JobId); assert.NotNil(t, task) {
+		assert.Equal(t, "/data/%j.out", task.GetBatchMeta().OutputFilePattern)
+	}
+}
+
+func TestSubmitJobUnknownUserHomedir(t *testing.T) {
+	_, conn := newTestServer(t)
+	client := protos.NewJobServiceClient(conn)
+
+	req := &protos.SubmitJobRequest{
+		UserId:           "scow_no_such_user",
+		JobName:          "test",
+		Account:          "a_admin",
+		Partition:        "CPU",
+		NodeCount:        1,
+		CoreCount:        1,
+		Script:           "sleep 100",
+		WorkingDirectory: "work",
+	}
+	_, err := client.SubmitJob(context.Background(), req)
+
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Equal(t, "USER_NOT_FOUND", errorReason(err))
+}
+
+func TestSubmitJobRejectedByCrane(t *testing.T) {
+	_, conn := newTestServer(t)
+	client := protos.NewJobServiceClient(conn)
+
+	req := &protos.SubmitJobRequest{
+		UserId:           currentUser(t),
+		JobName:          "test",
+		Account:          "not_exists",
+		Partition:        "CPU",
+		NodeCount:        1,
+		CoreCount:        1,
+		Script:           "sleep 100",
+		WorkingDirectory: "/tmp",
+	}
+	_, err := client.SubmitJob(context.Background(), req)
+
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.Equal(t, "ACCOUNT_NOT_FOUND", errorReason(err))
+}
+
+func TestSubmitJobRejectedByCraneForUnknownReason(t *testing.T) {
+	fake, conn := newTestServer(t)
+	client := protos.NewJobServiceClient(conn)
+	addTestAccount(t, fake, "a_admin")
+	if _, err := fake.BlockAccountOrUser(context.Background(), &craneProtos.BlockAccountOrUserRequest{Block: true, EntityType: craneProtos.EntityType_Account, Name: "a_admin"}); err != nil {
+		t.Fatalf("BlockAccountOrUser failed: %v", err)
+	}
+
+	req := &protos.SubmitJobRequest{
+		UserId:           currentUser(t),
+		JobName:          "test",
+		Account:          "a_admin",
+		Partition:        "CPU",
+		NodeCount:        1,
+		CoreCount:        1,
+		Script:           "sleep 100",
+		WorkingDirectory: "/tmp",
+	}
+	_, err := client.SubmitJob(context.Background(), req)
+
+	assert.Equal(t, codes.Internal, status.Code(err))
+	assert.Equal(t, "SBATCH_FAILED", errorReason(err))
+}
+
+// 替换cbatch提交，记录提交的脚本内容
+func stubLocalSubmitJob(t *testing.T, jobId uint32) *string {
+	submitted := new(string)
+	previous := localSubmitJob
+	localSubmitJob = func(ctx context.Context, scriptPath string, username string) (string, error) {
+		content, err := ioutil.ReadFile(scriptPath)
+		if err != nil {
+			return "", err
+		}
+		*submitted = string(content)
+		return fmt.Sprintf("Job id allocated: %d\n", jobId), nil
+	}
+	t.Cleanup(func() { localSubmitJob = previous })
+	return submitted
+}
+
+func TestSubmitScriptAsJob(t *testing.T) {
+	_, conn := newTestServer(t)
+	client := protos.NewJobServiceClient(conn)
+	scriptPath := "/data/jobs/job.sh"
+
+	tests := []struct {
+		name   string
+		script string
+		result string
+	}{
+		{"shebang", "#!/bin/bash\necho first\n", "#!/bin/bash\n#CBATCH --chdir /data/jobs\necho first\n"},
+		// 没有解释器行时第一行也要保留
+		{"no shebang", "echo first\necho second\n", "#CBATCH --chdir /data/jobs\necho first\necho second\n"},
+		{"shebang without trailing newline", "#!/bin/bash", "#!/bin/bash\n#CBATCH --chdir /data/jobs\n"},
+		{"existing chdir", "#!/bin/bash\n#CBATCH -D /home/demo\necho first\n", "#!/bin/bash\n#CBATCH -D /home/demo\necho first\n"},
+	}
+	for _, tt := range tests {
+		submitted := stubLocalSubmitJob(t, 7)
+
+		res, err := client.SubmitScriptAsJob(context.Background(), &protos.SubmitScriptAsJobRequest{UserId: "demo", Script: tt.script, ScriptFileFullPath: &scriptPath})
+		if err != nil {
+			t.Fatalf("SubmitScriptAsJob failed: %v", err)
+		}
+
+		assert.Equal(t, uint32(7), res.JobId, tt.name)
+		assert.Equal(t, tt.result, res.GetGeneratedScript(), tt.name)
+		assert.Equal(t, tt.result, *submitted, tt.name)
+	}
+}
+
+func TestGetJobById(t *testing.T) {
+	fake, conn := newTestServer(t)
+	client := protos.NewJobServiceClient(conn)
+	jobId := addTestTask(fake, "demo", "a_admin", craneProtos.TaskStatus_Running)
+
+	res, err := client.GetJobById(context.Background(), &protos.GetJobByIdRequest{JobId: jobId})
+	if err != nil {
+		t.Fatalf("GetJobById failed: %v", err)
+	}
+
+	assert.Equal(t, jobId, res.Job.JobId)
+	assert.Equal(t, "RUNNING", res.Job.State)
+	assert.Equal(t, "a_admin", res.Job.Account)
+	assert.Equal(t, "demo", res.Job.User)
+	assert.Equal(t, int64(60), res.Job.TimeLimitMinutes)
+	assert.Equal(t, int32(2), res.Job.GetCpusAlloc())
+	assert.Equal(t, "crane01", res.Job.GetNodeList())
+	assert.Equal(t, int32(1), res.Job.GetNodesAlloc())
+	assert.Equal(t, int64(4096), res.Job.GetMemAllocMb())
+	assert.Equal(t, int32(2), res.Job.GetGpusAlloc())
+}
+
+func TestGetJobByIdAllocFields(t *testing.T) {
+	fake, conn := newTestServer(t)
+	client := protos.NewJobServiceClient(conn)
+	jobId := addTestTask(fake, "demo", "a_admin", craneProtos.TaskStatus_Running)
+
+	res, err := client.GetJobById(context.Background(), &protos.GetJobByIdRequest{JobId: jobId, Fields: []string{"nodes_alloc", "gpus_alloc", "mem_alloc_mb"}})
+	if err != nil {
+		t.Fatalf("GetJobById failed: %v", err)
+	}
+
+	assert.Equal(t, int32(1), res.Job.GetNodesAlloc())
+	assert.Equal(t, int32(2), res.Job.GetGpusAlloc())
+	assert.Equal(t, int64(4096), res.Job.GetMemAllocMb())
+	assert.Empty(t, res.Job.State)
+}
+
+// CraneCtld返回的ResView是作业在所有节点上的资源之和
+func TestGetJobByIdAllocFieldsMultipleNodes(t *testing.T) {
+	fake, conn := newTestServer(t)
+	client := protos.NewJobServiceClient(conn)
+	jobId := fake.AddTask(&craneProtos.TaskInfo{
+		Name:       "train_model",
+		Partition:  "CPU",
+		Account:    "a_admin",
+		Username:   "demo",
+		Status:     craneProtos.TaskStatus_Running,
+		NodeNum:    2,
+		CranedList: "crane[01-02]",
+		StartTime:  timestamppb.Now(),
+		TimeLimit:  durationpb.New(time.Hour),
+		ResView: &craneProtos.ResourceView{
+			AllocatableRes: &craneProtos.AllocatableResource{CpuCoreLimit: 4, MemoryLimitBytes: 8192 * 1024 * 1024},
+			DeviceMap: &craneProtos.DeviceMap{NameTypeMap: map[string]*craneProtos.TypeCountMap{
+				"gpu": {TypeCountMap: map[string]uint64{"a100": 4}, Total: 4},
+			}},
+		},
+	})
+
+	res, err := client.GetJobById(context.Background(), &protos.GetJobByIdRequest{JobId: jobId, Fields: []string{"nodes_alloc", "gpus_alloc", "mem_alloc_mb"}})
+	if err != nil {
+		t.Fatalf("GetJobById failed: %v", err)
+	}
+
+	assert.Equal(t, int32(2), res.Job.GetNodesAlloc())
+	assert.Equal(t, int32(4), res.Job.GetGpusAlloc())
+	assert.Equal(t, int64(8192), res.Job.GetMemAllocMb())
+}
+
+func TestGetJobByIdMatchesGetJobs(t *testing.T) {
+	fake, conn := newTestServer(t)
+	client := protos.NewJobServiceClient(conn)
+	jobId := addTestTask(fake, "demo", "a_admin", craneProtos.TaskStatus_ExceedTimeLimit)
+
+	byId, err := client.GetJobById(context.Background(), &protos.GetJobByIdRequest{JobId: jobId})
+	if err != nil {
+		t.Fatalf("GetJobById failed: %v", err)
+	}
+	jobs, err := client.GetJobs(context.Background(), &protos.GetJobsRequest{Filter: &protos.GetJobsRequest_Filter{JobIds: []uint32{jobId}}})
+	if err != nil {
+		t.Fatalf("GetJobs failed: %v", err)
+	}
+
+	assert.Equal(t, "TIMEOUT", byId.Job.State)
+	assert.NotNil(t, byId.Job.EndTime)
+	if assert.Len(t, jobs.Jobs, 1) {
+		// 运行中作业的ElapsedSeconds随时间变化，这里的作业已经结束
+		assert.True(t, proto.Equal(jobs.Jobs[0], byId.Job))
+	}
+}
+
+func TestTaskGpusAlloc(t *testing.T) {
+	tests := []struct {
+		name      string
+		deviceMap *craneProtos.DeviceMap
+		gpus      int32
+	}{
+		{"no devices", nil, 0},
+		{"untyped", &craneProtos.DeviceMap{NameTypeMap: map[string]*craneProtos.TypeCountMap{
+			"gpu": {Total: 4},
+		}}, 4},
+		{"typed", &craneProtos.DeviceMap{NameTypeMap: map[string]*craneProtos.TypeCountMap{
+			"gpu": {TypeCountMap: map[string]uint64{"a100": 2, "v100": 1}, Total: 3},
+		}}, 3},
+		// 只统计GPU，不统计NPU等其他设备
+		{"other devices", &craneProtos.DeviceMap{NameTypeMap: map[string]*craneProtos.TypeCountMap{
+			"gpu": {TypeCountMap: map[string]uint64{"a100": 2}, Total: 2},
+			"npu": {Total: 1},
+		}}, 2},
+		{"no gpu", &craneProtos.DeviceMap{NameTypeMap: map[string]*craneProtos.TypeCountMap{
+			"npu": {Total: 4},
+		}}, 0},
+	}
+	for _, tt := range tests {
+		task := &craneProtos.TaskInfo{ResView: &craneProtos.ResourceView{DeviceMap: tt.deviceMap}}
+		assert.Equal(t, tt.gpus, taskGpusAlloc(task), tt.name)
+	}
+}
+
+func TestGetJobsSubmitTime(t *testing.T) {
+	fake, conn := newTestServer(t)
+	client := protos.NewJobServiceClient(conn)
+	pendingId := addTestTask(fake, "demo", "a_admin", craneProtos.TaskStatus_Pending)
+	runningId := addTestTask(fake, "demo", "a_admin", craneProtos.TaskStatus_Running)
+
+	for _, jobId := range []uint32{pendingId, runningId} {
+		task := fake.Task(jobId)
+		res, err := client.GetJobById(context.Background(), &protos.GetJobByIdRequest{JobId: jobId})
+		if err != nil {
+			t.Fatalf("GetJobById failed: %v", err)
+		}
+		assert.Equal(t, task.SubmitTime.AsTime(), res.Job.SubmitTime.AsTime())
+		assert.NotEqual(t, res.Job.StartTime.AsTime(), res.Job.SubmitTime.AsTime())
+	}
+
+	res, err := client.GetJobs(context.Background(), &protos.GetJobsRequest{})
+	if err != nil {
+		t.Fatalf("GetJobs failed: %v", err)
+	}
+	if assert.Len(t, res.Jobs, 2) {
+		for _, job := range res.Jobs {
+			assert.Equal(t, fake.Task(job.JobId).SubmitTime.AsTime(), job.SubmitTime.AsTime())
+		}
+	}
+}
+
+func TestGetJobByIdNotFound(t *testing.T) {
+	_, conn := newTestServer(t)
+	client := protos.NewJobServiceClient(conn)
+
+	_, err := client.GetJobById(context.Background(), &protos.GetJobByIdRequest{JobId: 100})
+
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.Equal(t, "JOB_NOT_FOUND", errorReason(err))
+}
+
+func TestGetJobs(t *testing.T) {
+	fake, conn := newTestServer(t)
+	client := protos.NewJobServiceClient(conn)
+	addTestTask(fake, "demo", "a_admin", craneProtos.TaskStatus_Running)
+	completedId := addTestTask(fake, "demo", "a_admin", craneProtos.TaskStatus_Completed)
+	addTestTask(fake, "other", "a_admin", craneProtos.TaskStatus_Completed)
+
+	req := &protos.GetJobsRequest{
+		Filter: &protos.GetJobsRequest_Filter{Users: []string{"demo"}, States: []string{"COMPLETED"}},
+	}
+	res, err := client.GetJobs(context.Background(), req)
+	if err != nil {
+		t.Fatalf("GetJobs failed: %v", err)
+	}
+
+	if assert.Len(t, res.Jobs, 1) {
+		assert.Equal(t, completedId, res.Jobs[0].JobId)
+		assert.Equal(t, "COMPLETED", res.Jobs[0].State)
+		assert.Equal(t, int64(4096), res.Jobs[0].GetMemAllocMb())
+		assert.Equal(t, int32(2), res.Jobs[0].GetGpusAlloc())
+	}
+	assert.Equal(t, uint32(1), res.GetTotalCount())
+}
+
+func TestGetJobsFields(t *testing.T) {
+	fake, conn := newTestServer(t)
+	client := protos.NewJobServiceClient(conn)
+	jobId := addTestTask(fake, "demo", "a_admin", craneProtos.TaskStatus_Pending)
+
+	res, err := client.GetJobs(context.Background(), &protos.GetJobsRequest{Fields: []string{"job_id", "state"}})
+	if err != nil {
+		t.Fatalf("GetJobs failed: %v", err)
+	}
+
+	if assert.Len(t, res.Jobs, 1) {
+		assert.Equal(t, jobId, res.Jobs[0].JobId)
+		assert.Equal(t, "PENDING", res.Jobs[0].State)
+		assert.Empty(t, res.Jobs[0].Account)
+	}
+}
+
+func TestQueryJobTimeLimit(t *testing.T) {
+	fake, conn := newTestServer(t)
+	client := protos.NewJobServiceClient(conn)
+	jobId := addTestTask(fake, "demo", "a_admin", craneProtos.TaskStatus_Running)
+
+	res, err := client.QueryJobTimeLimit(context.Background(), &protos.QueryJobTimeLimitRequest{JobId: jobId})
+	if err != nil {
+		t.Fatalf("QueryJobTimeLimit failed: %v", err)
+	}
+
+	assert.Equal(t, uint64(60), res.TimeLimitMinutes)
+}
+
+func TestChangeJobTimeLimit(t *testing.T) {
+	fake, conn := newTestServer(t)
+	client := protos.NewJobServiceClient(conn)
+	jobId := addTestTask(fake, "demo", "a_admin", craneProtos.TaskStatus_Running)
+
+	_, err := client.ChangeJobTimeLimit(context.Background(), &protos.ChangeJobTimeLimitRequest{JobId: jobId, DeltaMinutes: 30})
+	if err != nil {
+		t.Fatalf("ChangeJobTimeLimit failed: %v", err)
+	}
+
+	assert.Equal(t, int64(90*60), fake.Task(jobId).TimeLimit.Seconds)
+}
+
+func TestChangeJobTimeLimitNotPositive(t *testing.T) {
+	fake, conn := newTestServer(t)
+	client := protos.NewJobServiceClient(conn)
+	jobId := addTestTask(fake, "demo", "a_admin", craneProtos.TaskStatus_Running)
+
+	_, err := client.ChangeJobTimeLimit(context.Background(), &protos.ChangeJobTimeLimitRequest{JobId: jobId, DeltaMinutes: -60})
+
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Equal(t, "INVALID_TIME_LIMIT", errorReason(err))
+	assert.Equal(t, int64(60*60), fake.Task(jobId).TimeLimit.Seconds)
+}
+
+func TestJobTimeLimitJobNotFound(t *testing.T) {
+	_, conn := newTestServer(t)
+	client := protos.NewJobServiceClient(conn)
+
+	_, err := client.QueryJobTimeLimit(context.Background(), &protos.QueryJobTimeLimitRequest{JobId: 404})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.Equal(t, "JOB_NOT_FOUND", errorReason(err))
+
+	_, err = client.ChangeJobTimeLimit(context.Background(), &protos.ChangeJobTimeLimitRequest{JobId: 404, DeltaMinutes: 30})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.Equal(t, "JOB_NOT_FOUND", errorReason(err))
+}
+
+func TestCancelJob(t *testing.T) {
+	fake, conn := newTestServer(t)
+	client := protos.NewJobServiceClient(conn)
+	jobId := addTestTask(fake, "demo", "a_admin", craneProtos.TaskStatus_Running)
+
+	_, err := client.CancelJob(context.Background(), &protos.CancelJobRequest{UserId: "demo", JobId: jobId})
+	if err != nil {
+		t.Fatalf("CancelJob failed: %v", err)
+	}
+
+	assert.Equal(t, craneProtos.TaskStatus_Cancelled, fake.Task(jobId).Status)
+}
+
+func TestCancelJobNotFound(t *testing.T) {
+	_, conn := newTestServer(t)
+	client := protos.NewJobServiceClient(conn)
+
+	_, err := client.CancelJob(context.Background(), &protos.CancelJobRequest{UserId: "demo", JobId: 42})
+
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.Equal(t, "JOB_NOT_FOUND", errorReason(err))
+}
+
+func TestGetJobsPagination(t *testing.T) {
+	fake, conn := newTestServer(t)
+	client := protos.NewJobServiceClient(conn)
+	var jobIds []uint32
+	for i := 0; i < 5; i++ {
+		jobIds = append(jobIds, addTestTask(fake, "demo", "a_admin", craneProtos.TaskStatus_Completed))
+	}
+
+	res, err := client.GetJobs(context.Background(), &protos.GetJobsRequest{
+		PageInfo: &protos.PageInfo{Page: 2, PageSize: 2},
+	})
+	if err != nil {
+		t.Fatalf("GetJobs failed: %v", err)
+	}
+	if assert.Len(t, res.Jobs, 2) {
+		assert.Equal(t, jobIds[2], res.Jobs[0].JobId)
+		assert.Equal(t, jobIds[3], res.Jobs[1].JobId)
+	}
+	assert.Equal(t, uint32(5), res.GetTotalCount())
+
+	// 排序后再分页
+	res, err = client.GetJobs(context.Background(), &protos.GetJobsRequest{
+		Fields:   []string{"job_id"},
+		PageInfo: &protos.PageInfo{Page: 1, PageSize: 2},
+		Sort:     &protos.SortInfo{Field: "job_id", Order: protos.SortInfo_DESC},
+	})
+	if err != nil {
+		t.Fatalf("GetJobs failed: %v", err)
+	}
+	if assert.Len(t, res.Jobs, 2) {
+		assert.Equal(t, jobIds[4], res.Jobs[0].JobId)
+		assert.Equal(t, jobIds[3], res.Jobs[1].JobId)
+	}
+	assert.Equal(t, uint32(5), res.GetTotalCount())
+
+	// 超出范围的页返回空列表
+	res, err = client.GetJobs(context.Background(), &protos.GetJobsRequest{
+		PageInfo: &protos.PageInfo{Page: 4, PageSize: 2},
+	})
+	if err != nil {
+		t.Fatalf("GetJobs failed: %v", err)
+	}
+	assert.Empty(t, res.Jobs)
+	assert.Equal(t, uint32(5), res.GetTotalCount())
+}
+
+func TestGetJobsQueryIsBounded(t *testing.T) {
+	fake, conn := newTestServer(t)
+	client := protos.NewJobServiceClient(conn)
+	addTestTask(fake, "demo", "a_admin", craneProtos.TaskStatus_Completed)
+
+	res, err := client.GetJobs(context.Background(), &protos.GetJobsRequest{})
+	if err != nil {
+		t.Fatalf("GetJobs failed: %v", err)
+	}
+
+	// 不分页的请求也不会让CraneCtld返回所有历史作业
+	assert.Equal(t, adapterConfig.Jobs.MaxJobsPerRequest+1, fake.LastTasksQuery().GetNumLimit())
+	assert.Len(t, res.Jobs, 1)
+	assert.Equal(t, uint32(1), res.GetTotalCount())
+}
+
+func TestGetJobsMaxJobsPerRequest(t *testing.T) {
+	fake, conn := newTestServer(t)
+	client := protos.NewJobServiceClient(conn)
+	for i := 0; i < 5; i++ {
+		addTestTask(fake, "demo", "a_admin", craneProtos.TaskStatus_Completed)
+	}
+	adapterConfig.Jobs.MaxJobsPerRequest = 3
+
+	// 查询CraneCtld时限制作业数，多查询一个用于判断是否超过限制
+	res, err := client.GetJobs(context.Background(), &protos.GetJobsRequest{
+		PageInfo: &protos.PageInfo{Page: 1, PageSize: 2},
+	})
+	if err != nil {
+		t.Fatalf("GetJobs failed: %v", err)
+	}
+	assert.Equal(t, uint32(4), fake.LastTasksQuery().GetNumLimit())
+	assert.Len(t, res.Jobs, 2)
+	// 超过限制时TotalCount以MaxJobsPerRequest为上限
+	assert.Equal(t, uint32(3), res.GetTotalCount())
+
+	// 超出前MaxJobsPerRequest个作业的页为空
+	res, err = client.GetJobs(context.Background(), &protos.GetJobsRequest{
+		PageInfo: &protos.PageInfo{Page: 3, PageSize: 2},
+	})
+	if err != nil {
+		t.Fatalf("GetJobs failed: %v", err)
+	}
+	assert.Empty(t, res.Jobs)
+
+	// 不分页或需要排序的作业超过限制时返回错误
+	_, err = client.GetJobs(context.Background(), &protos.GetJobsRequest{})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.Equal(t, "TOO_MANY_JOBS", errorReason(err))
+	_, err = client.GetJobs(context.Background(), &protos.GetJobsRequest{
+		PageInfo: &protos.PageInfo{Page: 1, PageSize: 2},
+		Sort:     &protos.SortInfo{Field: "job_id"},
+	})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+}
+
+func TestGetJobsFilters(t *testing.T) {
+	fake, conn := newTestServer(t)
+	client := protos.NewJobServiceClient(conn)
+	fake.AddPartition(&craneProtos.PartitionInfo{Name: "GPU", State: craneProtos.PartitionState_PARTITION_UP})
+	adminJob := addTestTask(fake, "demo", "a_admin", craneProtos.TaskStatus_Completed)
+	otherJob := addTestTask(fake, "demo", "b_admin", craneProtos.TaskStatus_Completed)
+	gpuJob := fake.AddTask(&craneProtos.TaskInfo{Name: "train_model", Partition: "GPU", Account: "a_admin", Username: "demo", Status: craneProtos.TaskStatus_Running, StartTime: timestamppb.Now(), TimeLimit: durationpb.New(time.Hour)})
+
+	tests := []struct {
+		name   string
+		filter *protos.GetJobsRequest_Filter
+		jobIds []uint32
+	}{
+		{"accounts", &protos.GetJobsRequest_Filter{Accounts: []string{"a_admin"}}, []uint32{adminJob, gpuJob}},
+		{"accounts with end time", &protos.GetJobsRequest_Filter{
+			Accounts: []string{"b_admin"},
+			EndTime:  &protos.TimeRange{StartTime: timestamppb.New(time.Now().Add(-time.Hour))},
+		}, []uint32{otherJob}},
+		{"job ids", &protos.GetJobsRequest_Filter{JobIds: []uint32{otherJob, gpuJob}}, []uint32{otherJob, gpuJob}},
+		{"job name substring", &protos.GetJobsRequest_Filter{JobName: proto.String("model")}, []uint32{gpuJob}},
+		{"partitions", &protos.GetJobsRequest_Filter{Partitions: []string{"CPU"}}, []uint32{adminJob, otherJob}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := client.GetJobs(context.Background(), &protos.GetJobsRequest{Filter: tt.filter})
+			if err != nil {
+				t.Fatalf("GetJobs failed: %v", err)
+			}
+			var jobIds []uint32
+			for _, job := range res.Jobs {
+				jobIds = append(jobIds, job.JobId)
+			}
+			assert.Equal(t, tt.jobIds, jobIds)
+			assert.Equal(t, uint32(len(tt.jobIds)), res.GetTotalCount())
+		})
+	}
+}
+
+func TestGetJobsTimeRanges(t *testing.T) {
+	fake, conn := newTestServer(t)
+	client := protos.NewJobServiceClient(conn)
+	base := time.Now().Add(-24 * time.Hour).Truncate(time.Second)
+	at := func(hours int) *timestamppb.Timestamp {
+		return timestamppb.New(base.Add(time.Duration(hours) * time.Hour))
+	}
+	addJob := func(user string, account string, status craneProtos.TaskStatus, submit, start, end int) uint32 {
+		task := &craneProtos.TaskInfo{
+			Name:       "test",
+			Partition:  "CPU",
+			Account:    account,
+			Username:   user,
+			Status:     status,
+			TimeLimit:  durationpb.New(time.Hour),
+			SubmitTime: at(submit),
+			StartTime:  at(start),
+		}
+		if end != 0 {
+			task.EndTime = at(end)
+		}
+		return fake.AddTask(task)
+	}
+	early := addJob("demo", "a_admin", craneProtos.TaskStatus_Completed, 1, 2, 3)
+	middle := addJob("demo", "b_admin", craneProtos.TaskStatus_Failed, 5, 6, 7)
+	late := addJob("other", "a_admin", craneProtos.TaskStatus_Completed, 9, 10, 11)
+	running := addJob("demo", "a_admin", craneProtos.TaskStatus_Running, 12, 13, 0)
+
+	tests := []struct {
+		name   string
+		filter *protos.GetJobsRequest_Filter
+		jobIds []uint32
+	}{
+		{"end time lower bound", &protos.GetJobsRequest_Filter{EndTime: &protos.TimeRange{StartTime: at(4)}}, []uint32{middle, late}},
+		{"end time upper bound", &protos.GetJobsRequest_Filter{EndTime: &protos.TimeRange{EndTime: at(8)}}, []uint32{early, middle}},
+		{"end time both bounds", &protos.GetJobsRequest_Filter{EndTime: &protos.TimeRange{StartTime: at(4), EndTime: at(8)}}, []uint32{middle}},
+		{"start time lower bound", &protos.GetJobsRequest_Filter{StartTime: &protos.TimeRange{StartTime: at(6)}}, []uint32{middle, late, running}},
+		{"start time upper bound", &protos.GetJobsRequest_Filter{StartTime: &protos.TimeRange{EndTime: at(6)}}, []uint32{early, middle}},
+		{"submit time both bounds", &protos.GetJobsRequest_Filter{SubmitTime: &protos.TimeRange{StartTime: at(5), EndTime: at(12)}}, []uint32{middle, late, running}},
+		{"submit time with users and states", &protos.GetJobsRequest_Filter{
+			Users:      []string{"demo"},
+			States:     []string{"COMPLETED", "RUNNING"},
+			SubmitTime: &protos.TimeRange{StartTime: at(0)},
+		}, []uint32{early, running}},
+		{"start and end time with accounts", &protos.GetJobsRequest_Filter{
+			Accounts:  []string{"a_admin"},
+			StartTime: &protos.TimeRange{StartTime: at(1)},
+			EndTime:   &protos.TimeRange{EndTime: at(12)},
+		}, []uint32{early, late}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := client.GetJobs(context.Background(), &protos.GetJobsRequest{Filter: tt.filter})
+			if err != nil {
+				t.Fatalf("GetJobs failed: %v", err)
+			}
+			var jobIds []uint32
+			for _, job := range res.Jobs {
+				jobIds = append(jobIds, job.JobId)
+			}
+			assert.Equal(t, tt.jobIds, jobIds)
+		})
+	}
+}
+
+func TestGetJobsUnknownSortField(t *testing.T) {
+	fake, conn := newTestServer(t)
+	client := protos.NewJobServiceClient(conn)
+	addTestTask(fake, "demo", "a_admin", craneProtos.TaskStatus_Completed)
+
+	_, err := client.GetJobs(context.Background(), &protos.GetJobsRequest{
+		Sort: &protos.SortInfo{Field: "no_such_field"},
+	})
+
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Equal(t, "INVALID_SORT_FIELD", errorReason(err))
+}
+
+func TestGetJobsJobNameSubstringWithLimit(t *testing.T) {
+	fake, conn := newTestServer(t)
+	client := protos.NewJobServiceClient(conn)
+	for i := 0; i < 3; i++ {
+		addTestTask(fake, "demo", "a_admin", craneProtos.TaskStatus_Completed)
+	}
+	jobId := fake.AddTask(&craneProtos.TaskInfo{Name: "train_model", Partition: "CPU", Account: "a_admin", Username: "demo", Status: craneProtos.TaskStatus_Running, StartTime: timestamppb.Now(), TimeLimit: durationpb.New(time.Hour)})
+	adapterConfig.Jobs.MaxJobsPerRequest = 4
+
+	res, err := client.GetJobs(context.Background(), &protos.GetJobsRequest{
+		PageInfo: &protos.PageInfo{Page: 1, PageSize: 10},
+		Filter:   &protos.GetJobsRequest_Filter{JobName: proto.String("model")},
+	})
+	if err != nil {
+		t.Fatalf("GetJobs failed: %v", err)
+	}
+	if assert.Len(t, res.Jobs, 1) {
+		assert.Equal(t, jobId, res.Jobs[0].JobId)
+	}
+	assert.Equal(t, uint32(1), res.GetTotalCount())
+
+	// 查询到的作业超过限制时，适配器无法在所有作业中按子串筛选，要求缩小筛选范围
+	adapterConfig.Jobs.MaxJobsPerRequest = 2
+	_, err = client.GetJobs(context.Background(), &protos.GetJobsRequest{
+		PageInfo: &protos.PageInfo{Page: 1, PageSize: 10},
+		Filter:   &protos.GetJobsRequest_Filter{JobName: proto.String("model")},
+	})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.Equal(t, "TOO_MANY_JOBS", errorReason(err))
+}
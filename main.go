@@ -3,48 +3,59 @@ package main
 import (
 	"bufio"
 	"context"
+	"flag"
 	"fmt"
-	"io"
-	"log"
+	"math"
 	"math/rand"
 	"net"
 	"os"
+	"os/signal"
 	"path/filepath"
 	craneProtos "scow-crane-adapter/gen/crane"
 	protos "scow-crane-adapter/gen/go"
 	"scow-crane-adapter/utils"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/sirupsen/logrus"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
 	"google.golang.org/protobuf/types/known/timestamppb"
-	"gopkg.in/natefinch/lumberjack.v2"
 )
 
 var (
+	adapterConfig *utils.AdapterConfig
 	config        *utils.Config
-	stubCraneCtld craneProtos.CraneCtldClient
 	logger        *logrus.Logger
+	// 以用户身份执行cbatch提交脚本，测试中替换为不依赖cbatch的实现
+	localSubmitJob = utils.LocalSubmitJob
 )
 
 type serverJob struct {
 	protos.UnimplementedJobServiceServer
+	stubCraneCtld utils.CraneCtldClient
 }
 
 type serverAccount struct {
 	protos.UnimplementedAccountServiceServer
+	stubCraneCtld utils.CraneCtldClient
 }
 
 type serverUser struct {
 	protos.UnimplementedUserServiceServer
+	stubCraneCtld utils.CraneCtldClient
 }
 
 type serverConfig struct {
 	protos.UnimplementedConfigServiceServer
+	stubCraneCtld utils.CraneCtldClient
 }
 
 type serverVersion struct {
@@ -55,10 +66,6 @@ type serverApp struct {
 	protos.UnimplementedAppServiceServer
 }
 
-func init() {
-	config = utils.ParseConfig(utils.DefaultConfigPath)
-}
-
 // app
 func (s *serverApp) GetAppconnectionInfo(ctx context.Context, in *protos.GetAppConnectionInfoRequest) (*protos.GetAppConnectionInfoResponse, error) {
 	return &protos.GetAppConnectionInfoResponse{}, nil
@@ -66,8 +73,6 @@ func (s *serverApp) GetAppconnectionInfo(ctx context.Context, in *protos.GetAppC
 
 // version
 func (s *serverVersion) GetVersion(ctx context.Context, in *protos.GetVersionRequest) (*protos.GetVersionResponse, error) {
-	// 记录日志
-	logger.Infof("Received request GetVersion: %v", in)
 	return &protos.GetVersionResponse{Major: 1, Minor: 5, Patch: 0}, nil
 
 }
@@ -79,7 +84,10 @@ func (s *serverConfig) GetAvailablePartitions(ctx context.Context, in *protos.Ge
 	var (
 		partitions []*protos.Partition
 	)
-	qosList, _ := utils.GetQos()
+	qosList, err := utils.GetQos(ctx, s.stubCraneCtld)
+	if err != nil {
+		return nil, utils.CraneCallError(err)
+	}
 	qosListValue := utils.RemoveValue(qosList, "UNLIMITED")
 	if len(qosListValue) == 0 {
 		return nil, utils.RichError(codes.NotFound, "QOS_NOT_FOUND", "The qos not exists.")
@@ -90,12 +98,16 @@ func (s *serverConfig) GetAvailablePartitions(ctx context.Context, in *protos.Ge
 		request := &craneProtos.QueryPartitionInfoRequest{
 			PartitionName: partitionName,
 		}
-		response, err := stubCraneCtld.QueryPartitionInfo(context.Background(), request)
+		response, err := s.stubCraneCtld.QueryPartitionInfo(ctx, request)
 		if err != nil {
-			return nil, utils.RichError(codes.Internal, "CRANE_INTERNAL_ERROR", err.Error())
+			return nil, utils.CraneCallError(err)
+		}
+		if len(response.GetPartitionInfo()) == 0 {
+			message := fmt.Sprintf("Partition %s was not found in crane.", partitionName)
+			return nil, utils.RichError(codes.NotFound, "PARTITION_NOT_FOUND", message)
 		}
 		partitionValue := response.GetPartitionInfo()[0]
-		logger.Infof("%v", response.GetPartitionInfo())
+		logPartitionInfo(ctx, partitionValue)
 		partitions = append(partitions, &protos.Partition{
 			Name:  partitionValue.GetName(),
 			MemMb: partitionValue.GetTotalMem() / (1024 * 1024),
@@ -105,16 +117,47 @@ func (s *serverConfig) GetAvailablePartitions(ctx context.Context, in *protos.Ge
 			Qos:   qosListValue, // QOS是强行加进去的
 		})
 	}
-	logger.Infof("%v", partitions)
 	return &protos.GetAvailablePartitionsResponse{Partitions: partitions}, nil
 }
 
+// 在Debug级别记录从CraneCtld查询到的分区信息
+func logPartitionInfo(ctx context.Context, partition *craneProtos.PartitionInfo) {
+	utils.LoggerFromContext(ctx, logger).WithFields(logrus.Fields{
+		"partition":   partition.GetName(),
+		"state":       partition.GetState().String(),
+		"total_nodes": partition.GetTotalNodes(),
+		"total_cpu":   partition.GetTotalCpu(),
+		"total_mem":   partition.GetTotalMem(),
+	}).Debug("Queried partition info")
+}
+
 // 先在这里实现getclusterinfo的逻辑
 func (s *serverConfig) GetClusterInfo(ctx context.Context, in *protos.GetClusterInfoRequest) (*protos.GetClusterInfoResponse, error) {
 	var (
 		partitions []*protos.PartitionInfo
 	)
-	logger.Infof("Received request GetClusterInfo: %v", in)
+	// 一次查询所有正在运行和排队的作业，按分区统计作业数
+	runningJobNum := map[string]uint32{}
+	pendingJobNum := map[string]uint32{}
+	tasksRequest := &craneProtos.QueryTasksInfoRequest{
+		FilterTaskStates: []craneProtos.TaskStatus{craneProtos.TaskStatus_Running, craneProtos.TaskStatus_Pending},
+		NumLimit:         math.MaxUint32,
+	}
+	tasksResponse, err := s.stubCraneCtld.QueryTasksInfo(ctx, tasksRequest)
+	if err != nil {
+		return nil, utils.CraneCallError(err)
+	}
+	if !tasksResponse.GetOk() {
+		return nil, utils.CraneReplyError("")
+	}
+	for _, task := range tasksResponse.GetTaskInfoList() {
+		if task.GetStatus() == craneProtos.TaskStatus_Running {
+			runningJobNum[task.GetPartition()]++
+		} else if task.GetStatus() == craneProtos.TaskStatus_Pending {
+			pendingJobNum[task.GetPartition()]++
+		}
+	}
+
 	for _, part := range config.Partitions { // 遍历每个计算分区、分别获取信息  分区从接口获取
 		var runningNodes uint32
 		var state protos.PartitionInfo_PartitionStatus
@@ -124,39 +167,40 @@ func (s *serverConfig) GetClusterInfo(ctx context.Context, in *protos.GetCluster
 			PartitionName: partitionName,
 		}
 
-		response, err := stubCraneCtld.QueryPartitionInfo(context.Background(), request)
+		response, err := s.stubCraneCtld.QueryPartitionInfo(ctx, request)
 		if err != nil {
-			return nil, utils.RichError(codes.Internal, "CRANE_INTERNAL_ERROR", err.Error())
+			return nil, utils.CraneCallError(err)
+		}
+		if len(response.GetPartitionInfo()) == 0 {
+			message := fmt.Sprintf("Partition %s was not found in crane.", partitionName)
+			return nil, utils.RichError(codes.NotFound, "PARTITION_NOT_FOUND", message)
 		}
-		// 这里还要拿cqueue的值
-		runningJobCmd := fmt.Sprintf("cqueue -p %s -t r --noheader | wc -l", part.Name)                             // 获取正在运行作业的个数
-		pendingJobCmd := fmt.Sprintf("cqueue -p %s -t p --noheader | wc -l", part.Name)                             // 获取正在排队作业的个数
-		runningNodeCmd := fmt.Sprintf("cinfo -p %s -t alloc,mix | awk 'NR>1 {sum+=$4} END {print sum}'", part.Name) // 获取正在运行节点数
 
-		runningJobNumStr, err := utils.RunCommand(runningJobCmd) // 转化一下
-		if err != nil {
-			return nil, utils.RichError(codes.Internal, "CRANE_RUNCOMMAND_ERROR", err.Error())
+		// 获取分区中处于分配和部分分配状态的节点数
+		clusterRequest := &craneProtos.QueryClusterInfoRequest{
+			FilterPartitions:   []string{partitionName},
+			FilterCranedStates: []craneProtos.CranedState{craneProtos.CranedState_CRANE_ALLOC, craneProtos.CranedState_CRANE_MIX},
 		}
-		pendingJobNumStr, err := utils.RunCommand(pendingJobCmd) // 转化一下
+		clusterResponse, err := s.stubCraneCtld.QueryClusterInfo(ctx, clusterRequest)
 		if err != nil {
-			return nil, utils.RichError(codes.Internal, "CRANE_RUNCOMMAND_ERROR", err.Error())
+			return nil, utils.CraneCallError(err)
 		}
-		runningNodeStr, err := utils.RunCommand(runningNodeCmd)
-		if err != nil {
-			return nil, utils.RichError(codes.Internal, "CRANE_RUNCOMMAND_ERROR", err.Error())
+		if !clusterResponse.GetOk() {
+			return nil, utils.CraneReplyError("")
 		}
-		runningJobNum, _ := strconv.Atoi(runningJobNumStr)
-		pendingJobNum, _ := strconv.Atoi(pendingJobNumStr)
-		if runningNodeStr == "INFO[0000] No matching partitions were found for the given filter." {
-			runningNodes = 0
-		} else {
-			// 不为0的情况
-			tempNodes, _ := strconv.Atoi(runningNodeStr)
-			runningNodes = uint32(tempNodes)
+		for _, partitionCraned := range clusterResponse.GetPartitions() {
+			if partitionCraned.GetName() != partitionName {
+				continue
+			}
+			for _, craned := range partitionCraned.GetCranedLists() {
+				if craned.GetState() == craneProtos.CranedState_CRANE_ALLOC || craned.GetState() == craneProtos.CranedState_CRANE_MIX {
+					runningNodes += craned.GetCount()
+				}
+			}
 		}
 
 		partitionValue := response.GetPartitionInfo()[0]
-		logger.Infof("%v", response.GetPartitionInfo())
+		logPartitionInfo(ctx, partitionValue)
 		resultRatio := float64(runningNodes) / float64(partitionValue.TotalNodes)
 		percentage := int(resultRatio * 100) // 保留整数
 		if partitionValue.State == craneProtos.PartitionState_PARTITION_UP {
@@ -174,9 +218,9 @@ func (s *serverConfig) GetClusterInfo(ctx context.Context, in *protos.GetCluster
 			RunningCpuCount:       uint32(partitionValue.AllocCpu),
 			IdleCpuCount:          uint32(partitionValue.TotalCpu) - uint32(partitionValue.AllocCpu),
 			NotAvailableCpuCount:  uint32(partitionValue.TotalCpu) - uint32(partitionValue.AvailCpu) - uint32(partitionValue.AllocCpu),
-			JobCount:              uint32(runningJobNum) + uint32(pendingJobNum),
-			RunningJobCount:       uint32(runningJobNum),
-			PendingJobCount:       uint32(pendingJobNum),
+			JobCount:              runningJobNum[partitionName] + pendingJobNum[partitionName],
+			RunningJobCount:       runningJobNum[partitionName],
+			PendingJobCount:       pendingJobNum[partitionName],
 			UsageRatePercentage:   uint32(percentage),
 			PartitionStatus:       state,
 		})
@@ -189,10 +233,11 @@ func (s *serverConfig) GetClusterConfig(ctx context.Context, in *protos.GetClust
 	var (
 		partitions []*protos.Partition
 	)
-	logger.Infof("Received request GetClusterConfig: %v", in)
-
 	// 获取系统Qos
-	qosList, _ := utils.GetQos()
+	qosList, err := utils.GetQos(ctx, s.stubCraneCtld)
+	if err != nil {
+		return nil, utils.CraneCallError(err)
+	}
 	qosListValue := utils.RemoveValue(qosList, "UNLIMITED")
 	if len(qosListValue) == 0 {
 		return nil, utils.RichError(codes.NotFound, "QOS_NOT_FOUND", "The qos not exists.")
@@ -203,12 +248,16 @@ func (s *serverConfig) GetClusterConfig(ctx context.Context, in *protos.GetClust
 		request := &craneProtos.QueryPartitionInfoRequest{
 			PartitionName: partitionName,
 		}
-		response, err := stubCraneCtld.QueryPartitionInfo(context.Background(), request)
+		response, err := s.stubCraneCtld.QueryPartitionInfo(ctx, request)
 		if err != nil {
-			return nil, utils.RichError(codes.Internal, "CRANE_INTERNAL_ERROR", err.Error())
+			return nil, utils.CraneCallError(err)
+		}
+		if len(response.GetPartitionInfo()) == 0 {
+			message := fmt.Sprintf("Partition %s was not found in crane.", partitionName)
+			return nil, utils.RichError(codes.NotFound, "PARTITION_NOT_FOUND", message)
 		}
 		partitionValue := response.GetPartitionInfo()[0]
-		logger.Infof("%v", response.GetPartitionInfo())
+		logPartitionInfo(ctx, partitionValue)
 		partitions = append(partitions, &protos.Partition{
 			Name:  partitionValue.GetName(),
 			MemMb: partitionValue.GetTotalMem() / (1024 * 1024),
@@ -218,18 +267,88 @@ func (s *serverConfig) GetClusterConfig(ctx context.Context, in *protos.GetClust
 			Qos:   qosListValue, // QOS是强行加进去的
 		})
 	}
-	logger.Infof("%v", partitions)
 	return &protos.GetClusterConfigResponse{Partitions: partitions, SchedulerName: "Crane"}, nil
 }
 
+// 查询账户当前的信息，账户不存在时返回nil
+func queryAccount(ctx context.Context, stubCraneCtld utils.CraneCtldClient, accountName string) (*craneProtos.AccountInfo, error) {
+	request := &craneProtos.QueryEntityInfoRequest{
+		Uid:        0,
+		EntityType: craneProtos.EntityType_Account,
+		Name:       accountName,
+	}
+	response, err := stubCraneCtld.QueryEntityInfo(ctx, request)
+	if err != nil {
+		return nil, utils.CraneCallError(err)
+	}
+	if !response.GetOk() {
+		err := utils.CraneReplyError(response.GetReason())
+		if utils.ErrorReason(err) == "ACCOUNT_NOT_FOUND" {
+			return nil, nil
+		}
+		return nil, err
+	}
+	for _, account := range response.GetAccountList() {
+		if account.GetName() == accountName {
+			return account, nil
+		}
+	}
+	return nil, nil
+}
+
+// 查询用户在账户中的信息，账户不存在时返回ACCOUNT_NOT_FOUND，用户不在账户中时返回nil
+func queryUserInAccount(ctx context.Context, stubCraneCtld utils.CraneCtldClient, userId string, accountName string) (*craneProtos.UserInfo, error) {
+	account, err := queryAccount(ctx, stubCraneCtld, accountName)
+	if err != nil {
+		return nil, err
+	}
+	if account == nil {
+		return nil, utils.RichError(codes.NotFound, "ACCOUNT_NOT_FOUND", fmt.Sprintf("Account %s does not exist.", accountName))
+	}
+	request := &craneProtos.QueryEntityInfoRequest{
+		Uid:        0,
+		EntityType: craneProtos.EntityType_User,
+		Name:       userId,
+		Account:    accountName,
+	}
+	response, err := stubCraneCtld.QueryEntityInfo(ctx, request)
+	if err != nil {
+		return nil, utils.CraneCallError(err)
+	}
+	if !response.GetOk() {
+		err := utils.CraneReplyError(response.GetReason())
+		switch utils.ErrorReason(err) {
+		case "USER_NOT_FOUND", "ASSOCIATION_NOT_EXISTS":
+			return nil, nil
+		}
+		return nil, err
+	}
+	for _, user := range response.GetUserList() {
+		// 用户的默认账户会带有*后缀
+		if user.GetName() == userId && strings.TrimSuffix(user.GetAccount(), "*") == accountName {
+			return user, nil
+		}
+	}
+	return nil, nil
+}
+
 func (s *serverUser) AddUserToAccount(ctx context.Context, in *protos.AddUserToAccountRequest) (*protos.AddUserToAccountResponse, error) {
 	var (
 		allowedPartitionQosList []*craneProtos.UserInfo_AllowedPartitionQos
 	)
-	logger.Infof("Received request AddUserToAccount: %v", in)
-
+	// 用户已经在账户中时直接返回成功，SCOW重试时不会报错
+	existingUser, err := queryUserInAccount(ctx, s.stubCraneCtld, in.UserId, in.AccountName)
+	if err != nil {
+		return nil, err
+	}
+	if existingUser != nil {
+		return &protos.AddUserToAccountResponse{}, nil
+	}
 	// 获取crane中QOS列表
-	qosList, _ := utils.GetQos()
+	qosList, err := utils.GetQos(ctx, s.stubCraneCtld)
+	if err != nil {
+		return nil, utils.CraneCallError(err)
+	}
 	qosListValue := utils.RemoveValue(qosList, "UNLIMITED")
 
 	if len(qosListValue) == 0 {
@@ -261,19 +380,17 @@ func (s *serverUser) AddUserToAccount(ctx context.Context, in *protos.AddUserToA
 		Uid:  0,
 		User: user,
 	}
-	response, err := stubCraneCtld.AddUser(context.Background(), request)
+	response, err := s.stubCraneCtld.AddUser(ctx, request)
 	if err != nil {
-		return nil, utils.RichError(codes.Unavailable, "CRANE_CALL_FAILED", err.Error())
+		return nil, utils.CraneCallError(err)
 	}
 	if !response.GetOk() {
-		return nil, utils.RichError(codes.NotFound, "ACCOUNT_NOT_FOUND", response.GetReason())
+		return nil, utils.CraneReplyError(response.GetReason())
 	}
 	return &protos.AddUserToAccountResponse{}, nil
 }
 
 func (s *serverUser) RemoveUserFromAccount(ctx context.Context, in *protos.RemoveUserFromAccountRequest) (*protos.RemoveUserFromAccountResponse, error) {
-	// 记录日志
-	logger.Infof("Received request RemoveUserFromAccount: %v", in)
 	request := &craneProtos.DeleteEntityRequest{
 		Uid:        0, // 操作者
 		EntityType: craneProtos.EntityType_User,
@@ -281,19 +398,28 @@ func (s *serverUser) RemoveUserFromAccount(ctx context.Context, in *protos.Remov
 		Name:       in.UserId,
 	}
 
-	response, err := stubCraneCtld.DeleteEntity(context.Background(), request)
+	response, err := s.stubCraneCtld.DeleteEntity(ctx, request)
 	if err != nil {
-		return nil, utils.RichError(codes.Unavailable, "CRANE_CALL_FAILED", err.Error())
+		return nil, utils.CraneCallError(err)
 	}
 	if !response.GetOk() {
-		return nil, utils.RichError(codes.NotFound, "ASSOCIATION_NOT_EXISTS", response.GetReason())
+		return nil, utils.CraneReplyError(response.GetReason())
 	}
 	return &protos.RemoveUserFromAccountResponse{}, nil
 }
 
 func (s *serverUser) BlockUserInAccount(ctx context.Context, in *protos.BlockUserInAccountRequest) (*protos.BlockUserInAccountResponse, error) {
-	// 记录日志
-	logger.Infof("Received request BlockUserInAccount: %v", in)
+	user, err := queryUserInAccount(ctx, s.stubCraneCtld, in.UserId, in.AccountName)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, utils.RichError(codes.NotFound, "ASSOCIATION_NOT_EXISTS", fmt.Sprintf("User %s is not in account %s.", in.UserId, in.AccountName))
+	}
+	// 用户已经被封锁时直接返回成功
+	if user.GetBlocked() == true {
+		return &protos.BlockUserInAccountResponse{}, nil
+	}
 	request := &craneProtos.BlockAccountOrUserRequest{
 		Block:      true,
 		Uid:        0, // 操作者
@@ -301,32 +427,41 @@ func (s *serverUser) BlockUserInAccount(ctx context.Context, in *protos.BlockUse
 		Name:       in.UserId,
 		Account:    in.AccountName,
 	}
-	response, err := stubCraneCtld.BlockAccountOrUser(context.Background(), request)
+	response, err := s.stubCraneCtld.BlockAccountOrUser(ctx, request)
 	if err != nil {
-		return nil, utils.RichError(codes.Unavailable, "CRANE_CALL_FAILED", err.Error())
+		return nil, utils.CraneCallError(err)
 	}
 	if !response.GetOk() {
-		return nil, utils.RichError(codes.NotFound, "ASSOCIATION_NOT_EXISTS", response.GetReason())
+		return nil, utils.CraneReplyError(response.GetReason())
 	}
 	return &protos.BlockUserInAccountResponse{}, nil
 }
 
 func (s *serverUser) UnblockUserInAccount(ctx context.Context, in *protos.UnblockUserInAccountRequest) (*protos.UnblockUserInAccountResponse, error) {
-	// 记录日志
-	logger.Infof("Received request UnblockUserInAccount: %v", in)
+	user, err := queryUserInAccount(ctx, s.stubCraneCtld, in.UserId, in.AccountName)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, utils.RichError(codes.NotFound, "ASSOCIATION_NOT_EXISTS", fmt.Sprintf("User %s is not in account %s.", in.UserId, in.AccountName))
+	}
+	// 用户没有被封锁时直接返回成功
+	if user.GetBlocked() == false {
+		return &protos.UnblockUserInAccountResponse{}, nil
+	}
 	request := &craneProtos.BlockAccountOrUserRequest{
 		Block:      false,
-		Uid:        0,
+		Uid:        0, // 操作者
 		EntityType: craneProtos.EntityType_User,
 		Name:       in.UserId,
 		Account:    in.AccountName,
 	}
-	response, err := stubCraneCtld.BlockAccountOrUser(context.Background(), request)
+	response, err := s.stubCraneCtld.BlockAccountOrUser(ctx, request)
 	if err != nil {
-		return nil, utils.RichError(codes.Unavailable, "CRANE_CALL_FAILED", err.Error())
+		return nil, utils.CraneCallError(err)
 	}
 	if !response.GetOk() {
-		return nil, utils.RichError(codes.NotFound, "ASSOCIATION_NOT_EXISTS", response.GetReason())
+		return nil, utils.CraneReplyError(response.GetReason())
 	}
 	return &protos.UnblockUserInAccountResponse{}, nil
 }
@@ -335,24 +470,22 @@ func (s *serverUser) QueryUserInAccountBlockStatus(ctx context.Context, in *prot
 	var (
 		blocked bool
 	)
-	// 记录日志
-	logger.Infof("Received request QueryUserInAccountBlockStatus: %v", in)
 	request := &craneProtos.QueryEntityInfoRequest{
 		Uid:        0,
 		EntityType: craneProtos.EntityType_User,
 		Name:       in.UserId,
 		Account:    in.AccountName,
 	}
-	response, err := stubCraneCtld.QueryEntityInfo(context.Background(), request)
+	response, err := s.stubCraneCtld.QueryEntityInfo(ctx, request)
 	if err != nil {
-		return nil, utils.RichError(codes.Unavailable, "CRANE_CALL_FAILED", err.Error())
+		return nil, utils.CraneCallError(err)
+	}
+	if !response.GetOk() {
+		return nil, utils.CraneReplyError(response.GetReason())
 	}
 	for _, v := range response.GetUserList() {
 		blocked = v.GetBlocked()
 	}
-	if !response.GetOk() {
-		return nil, utils.RichError(codes.Internal, "CRANE_INTERNAL_ERROR", response.GetReason())
-	}
 	return &protos.QueryUserInAccountBlockStatusResponse{Blocked: blocked}, nil
 }
 
@@ -360,21 +493,18 @@ func (s *serverAccount) ListAccounts(ctx context.Context, in *protos.ListAccount
 	var (
 		accountList []string
 	)
-	// 记录日志
-	logger.Infof("Received request ListAccounts: %v", in)
-
 	// 请求体
 	request := &craneProtos.QueryEntityInfoRequest{
 		Uid:        0,
 		EntityType: craneProtos.EntityType_User,
 		Name:       in.UserId,
 	}
-	response, err := stubCraneCtld.QueryEntityInfo(context.Background(), request)
+	response, err := s.stubCraneCtld.QueryEntityInfo(ctx, request)
 	if err != nil {
-		return nil, utils.RichError(codes.Unavailable, "CRANE_CALL_FAILED", err.Error())
+		return nil, utils.CraneCallError(err)
 	}
 	if !response.GetOk() {
-		return nil, utils.RichError(codes.Internal, "CRANE_INTERNAL_ERROR", response.GetReason())
+		return nil, utils.CraneReplyError(response.GetReason())
 	}
 
 	// 获取账户列表信息
@@ -391,22 +521,47 @@ func (s *serverAccount) ListAccounts(ctx context.Context, in *protos.ListAccount
 
 func (s *serverAccount) CreateAccount(ctx context.Context, in *protos.CreateAccountRequest) (*protos.CreateAccountResponse, error) {
 	var (
-		partitionList           []string
-		qosList                 []string
-		allowedPartitionQosList []*craneProtos.UserInfo_AllowedPartitionQos
+		partitionList []string
+		qosList       []string
 	)
-	// 记录日志
-	logger.Infof("Received request CreateAccount: %v", in)
 	// 获取计算分区信息
 	for _, partition := range config.Partitions {
 		partitionList = append(partitionList, partition.Name)
 	}
 	// 获取系统QOS
-	qosList, _ = utils.GetQos()
+	qosList, err := utils.GetQos(ctx, s.stubCraneCtld)
+	if err != nil {
+		return nil, utils.CraneCallError(err)
+	}
 	qosListValue := utils.RemoveValue(qosList, "UNLIMITED")
 	if len(qosListValue) == 0 {
 		return nil, utils.RichError(codes.NotFound, "QOS_NOT_FOUND", "The qos is not exists.")
 	}
+	// 先检查账户拥有者是否存在，避免账户创建后无法添加拥有者
+	uid, err := utils.GetUidByUserName(in.OwnerUserId)
+	if err != nil {
+		return nil, utils.RichError(codes.NotFound, "USER_NOT_FOUND", "The user is not exists.")
+	}
+	// 账户已经存在且拥有者已在账户中时，说明是SCOW的重试，直接返回成功
+	// 账户中还没有用户时，说明之前的创建在添加拥有者时失败且回滚也失败，继续完成添加拥有者
+	existingAccount, err := queryAccount(ctx, s.stubCraneCtld, in.AccountName)
+	if err != nil {
+		return nil, err
+	}
+	if existingAccount != nil {
+		for _, user := range existingAccount.GetUsers() {
+			if user == in.OwnerUserId {
+				return &protos.CreateAccountResponse{}, nil
+			}
+		}
+		if len(existingAccount.GetUsers()) != 0 {
+			return nil, utils.RichError(codes.AlreadyExists, "ACCOUNT_ALREADY_EXISTS", fmt.Sprintf("Account %s already exists.", in.AccountName))
+		}
+		if err := s.addAccountOwner(ctx, in, uid, qosListValue); err != nil {
+			return nil, err
+		}
+		return &protos.CreateAccountResponse{}, nil
+	}
 
 	AccountInfo := &craneProtos.AccountInfo{
 		Name:              in.AccountName,
@@ -420,14 +575,23 @@ func (s *serverAccount) CreateAccount(ctx context.Context, in *protos.CreateAcco
 		Uid:     uint32(os.Getuid()),
 		Account: AccountInfo,
 	}
-	response, err := stubCraneCtld.AddAccount(context.Background(), request)
+	response, err := s.stubCraneCtld.AddAccount(ctx, request)
 	if err != nil {
-		return nil, utils.RichError(codes.Unavailable, "CRANE_CALL_FAILED", err.Error())
+		return nil, utils.CraneCallError(err)
 	}
 	if !response.GetOk() {
-		return nil, utils.RichError(codes.Internal, "CRANE_INTERNAL_ERROR", response.GetReason())
+		return nil, utils.CraneReplyError(response.GetReason())
+	}
+	// 账户创建成功后，将用户添加至账户中，失败时删除刚创建的账户
+	if err := s.addAccountOwner(ctx, in, uid, qosListValue); err != nil {
+		return nil, s.rollbackCreateAccount(ctx, in.AccountName, err)
 	}
-	// 账户创建成功后，将用户添加至账户中
+	return &protos.CreateAccountResponse{}, nil
+}
+
+// 将账户拥有者以Operator身份添加到账户中
+func (s *serverAccount) addAccountOwner(ctx context.Context, in *protos.CreateAccountRequest, uid int, qosListValue []string) error {
+	var allowedPartitionQosList []*craneProtos.UserInfo_AllowedPartitionQos
 	for _, partition := range config.Partitions {
 		allowedPartitionQosList = append(allowedPartitionQosList, &craneProtos.UserInfo_AllowedPartitionQos{
 			PartitionName: partition.Name,
@@ -435,10 +599,6 @@ func (s *serverAccount) CreateAccount(ctx context.Context, in *protos.CreateAcco
 			DefaultQos:    qosListValue[0],
 		})
 	}
-	uid, err := utils.GetUidByUserName(in.OwnerUserId)
-	if err != nil {
-		return nil, utils.RichError(codes.NotFound, "USER_NOT_FOUND", "The user is not exists.")
-	}
 	user := &craneProtos.UserInfo{
 		Uid:                     uint32(uid),
 		Name:                    in.OwnerUserId,
@@ -451,19 +611,53 @@ func (s *serverAccount) CreateAccount(ctx context.Context, in *protos.CreateAcco
 		Uid:  0,
 		User: user,
 	}
-	responseUser, err := stubCraneCtld.AddUser(context.Background(), requestAddUser)
+	responseUser, err := s.stubCraneCtld.AddUser(ctx, requestAddUser)
 	if err != nil {
-		return nil, utils.RichError(codes.Unavailable, "CRANE_CALL_FAILED", err.Error())
+		return utils.CraneCallError(err)
 	}
 	if !responseUser.GetOk() {
-		return nil, utils.RichError(codes.NotFound, "ACCOUNT_NOT_FOUND", responseUser.GetReason())
+		return utils.CraneReplyError(responseUser.GetReason())
 	}
-	return &protos.CreateAccountResponse{}, nil
+	return nil
+}
+
+// 添加账户拥有者失败时删除刚创建的账户，保证CreateAccount要么全部完成要么不产生影响
+// 返回原始错误，删除也失败时在错误信息中说明，SCOW重试CreateAccount时会继续添加拥有者
+func (s *serverAccount) rollbackCreateAccount(ctx context.Context, accountName string, cause error) error {
+	requestLogger := utils.LoggerFromContext(ctx, logger)
+	// 请求可能已经超时或被取消，回滚使用单独的context
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	request := &craneProtos.DeleteEntityRequest{
+		Uid:        0,
+		EntityType: craneProtos.EntityType_Account,
+		Name:       accountName,
+	}
+	response, err := s.stubCraneCtld.DeleteEntity(ctx, request)
+	if err == nil && !response.GetOk() {
+		err = fmt.Errorf("%s", response.GetReason())
+	}
+	if err != nil {
+		requestLogger.WithField("account", accountName).Errorf("Failed to roll back account: %v", err)
+		st := status.Convert(cause)
+		return utils.RichError(st.Code(), utils.ErrorReason(cause),
+			fmt.Sprintf("%s (rollback of account %s failed: %v)", st.Message(), accountName, err))
+	}
+	return cause
 }
 
 func (s *serverAccount) BlockAccount(ctx context.Context, in *protos.BlockAccountRequest) (*protos.BlockAccountResponse, error) {
-	// 记录日志
-	logger.Infof("Received request BlockAccount: %v", in)
+	account, err := queryAccount(ctx, s.stubCraneCtld, in.AccountName)
+	if err != nil {
+		return nil, err
+	}
+	if account == nil {
+		return nil, utils.RichError(codes.NotFound, "ACCOUNT_NOT_FOUND", fmt.Sprintf("Account %s does not exist.", in.AccountName))
+	}
+	// 账户已经被封锁时直接返回成功
+	if account.GetBlocked() == true {
+		return &protos.BlockAccountResponse{}, nil
+	}
 	// 请求体 封锁账户
 	request := &craneProtos.BlockAccountOrUserRequest{
 		Block:      true,
@@ -471,20 +665,28 @@ func (s *serverAccount) BlockAccount(ctx context.Context, in *protos.BlockAccoun
 		Name:       in.AccountName,
 		Uid:        0,
 	}
-	response, err := stubCraneCtld.BlockAccountOrUser(context.Background(), request)
+	response, err := s.stubCraneCtld.BlockAccountOrUser(ctx, request)
 	if err != nil {
-		return nil, utils.RichError(codes.Unavailable, "CRANE_CALL_FAILED", err.Error())
+		return nil, utils.CraneCallError(err)
 	}
 	if !response.GetOk() {
-		return nil, utils.RichError(codes.AlreadyExists, "ACCOUNT_ALREADY_EXISTS", response.GetReason())
-	} else {
-		return &protos.BlockAccountResponse{}, nil
+		return nil, utils.CraneReplyError(response.GetReason())
 	}
+	return &protos.BlockAccountResponse{}, nil
 }
 
 func (s *serverAccount) UnblockAccount(ctx context.Context, in *protos.UnblockAccountRequest) (*protos.UnblockAccountResponse, error) {
-	// 记录日志
-	logger.Infof("Received request UnblockAccount: %v", in)
+	account, err := queryAccount(ctx, s.stubCraneCtld, in.AccountName)
+	if err != nil {
+		return nil, err
+	}
+	if account == nil {
+		return nil, utils.RichError(codes.NotFound, "ACCOUNT_NOT_FOUND", fmt.Sprintf("Account %s does not exist.", in.AccountName))
+	}
+	// 账户没有被封锁时直接返回成功
+	if account.GetBlocked() == false {
+		return &protos.UnblockAccountResponse{}, nil
+	}
 	// 请求体 解封账户
 	request := &craneProtos.BlockAccountOrUserRequest{
 		Block:      false,
@@ -492,32 +694,29 @@ func (s *serverAccount) UnblockAccount(ctx context.Context, in *protos.UnblockAc
 		Name:       in.AccountName,
 		Uid:        0,
 	}
-	response, err := stubCraneCtld.BlockAccountOrUser(context.Background(), request)
+	response, err := s.stubCraneCtld.BlockAccountOrUser(ctx, request)
 	if err != nil {
-		return nil, utils.RichError(codes.Unavailable, "CRANE_CALL_FAILED", err.Error())
+		return nil, utils.CraneCallError(err)
 	}
 	if !response.GetOk() {
-		return nil, utils.RichError(codes.AlreadyExists, "ACCOUNT_ALREADY_EXISTS", response.GetReason())
-	} else {
-		return &protos.UnblockAccountResponse{}, nil
+		return nil, utils.CraneReplyError(response.GetReason())
 	}
+	return &protos.UnblockAccountResponse{}, nil
 }
 
 func (s *serverAccount) GetAllAccountsWithUsers(ctx context.Context, in *protos.GetAllAccountsWithUsersRequest) (*protos.GetAllAccountsWithUsersResponse, error) {
 	var (
 		accounts []*protos.ClusterAccountInfo
 	)
-	// 记录日志
-	logger.Infof("Received request GetAllAccountsWithUsers: %v", in)
 	request := &craneProtos.QueryEntityInfoRequest{
 		Uid: 0,
 	}
-	response, err := stubCraneCtld.QueryEntityInfo(context.Background(), request)
+	response, err := s.stubCraneCtld.QueryEntityInfo(ctx, request)
 	if err != nil {
-		return nil, utils.RichError(codes.Unavailable, "CRANE_CALL_FAILED", err.Error())
+		return nil, utils.CraneCallError(err)
 	}
 	if !response.GetOk() {
-		return nil, utils.RichError(codes.Internal, "CRANE_INTERNAL_ERROR", response.GetReason())
+		return nil, utils.CraneReplyError(response.GetReason())
 	}
 	// 获取所有账户信息
 	for _, account := range response.GetAccountList() {
@@ -528,7 +727,13 @@ func (s *serverAccount) GetAllAccountsWithUsers(ctx context.Context, in *protos.
 			EntityType: craneProtos.EntityType_User,
 		}
 		// 获取单个账户下用户信息
-		responseUser, _ := stubCraneCtld.QueryEntityInfo(context.Background(), requestUser)
+		responseUser, err := s.stubCraneCtld.QueryEntityInfo(ctx, requestUser)
+		if err != nil {
+			return nil, utils.CraneCallError(err)
+		}
+		if !responseUser.GetOk() {
+			return nil, utils.CraneReplyError(responseUser.GetReason())
+		}
 		for _, user := range responseUser.GetUserList() {
 			userInfo = append(userInfo, &protos.ClusterAccountInfo_UserInAccount{
 				UserId:   user.GetName(),
@@ -549,20 +754,19 @@ func (s *serverAccount) QueryAccountBlockStatus(ctx context.Context, in *protos.
 	var (
 		blocked bool
 	)
-	logger.Infof("Received request QueryAccountBlockStatus: %v", in)
 	// 请求体
 	request := &craneProtos.QueryEntityInfoRequest{
 		Uid:        0,
 		EntityType: craneProtos.EntityType_Account,
 		Name:       in.AccountName,
 	}
-	response, err := stubCraneCtld.QueryEntityInfo(context.Background(), request)
+	response, err := s.stubCraneCtld.QueryEntityInfo(ctx, request)
 	if err != nil {
-		return nil, utils.RichError(codes.Unavailable, "CRANE_CALL_FAILED", err.Error())
+		return nil, utils.CraneCallError(err)
 	}
 
 	if !response.GetOk() {
-		return nil, utils.RichError(codes.NotFound, "ACCOUNT_NOT_FOUND", response.GetReason())
+		return nil, utils.CraneReplyError(response.GetReason())
 	}
 
 	// 获取单个用户的封锁状态
@@ -574,15 +778,22 @@ func (s *serverAccount) QueryAccountBlockStatus(ctx context.Context, in *protos.
 }
 
 func (s *serverJob) CancelJob(ctx context.Context, in *protos.CancelJobRequest) (*protos.CancelJobResponse, error) {
-	logger.Infof("Received request CancelJob: %v", in)
 	request := &craneProtos.CancelTaskRequest{
 		OperatorUid:   0,
 		FilterTaskIds: []uint32{uint32(in.JobId)},
 		FilterState:   craneProtos.TaskStatus_Invalid,
 	}
-	_, err := stubCraneCtld.CancelTask(context.Background(), request)
+	response, err := s.stubCraneCtld.CancelTask(ctx, request)
 	if err != nil {
-		return nil, utils.RichError(codes.Unavailable, "CRANE_CALL_FAILED", "Crane service call failed.")
+		return nil, utils.CraneCallError(err)
+	}
+	// 没有被取消的作业带有CraneCtld给出的原因
+	for i, taskId := range response.GetNotCancelledTasks() {
+		var reason string
+		if i < len(response.GetNotCancelledReasons()) {
+			reason = response.GetNotCancelledReasons()[i]
+		}
+		return nil, utils.CraneReplyError(fmt.Sprintf("Task #%d was not cancelled: %s", taskId, reason))
 	}
 	return &protos.CancelJobResponse{}, nil
 }
@@ -592,30 +803,28 @@ func (s *serverJob) QueryJobTimeLimit(ctx context.Context, in *protos.QueryJobTi
 		jobIdList []uint32
 		seconds   uint64
 	)
-	logger.Infof("Received request QueryJobTimeLimit: %v", in)
-
 	jobIdList = append(jobIdList, in.JobId)
 	request := &craneProtos.QueryTasksInfoRequest{
 		FilterTaskIds:               jobIdList,
 		OptionIncludeCompletedTasks: true, // 包含运行结束的作业
 	}
-	response, err := stubCraneCtld.QueryTasksInfo(context.Background(), request)
+	response, err := s.stubCraneCtld.QueryTasksInfo(ctx, request)
 	if err != nil {
-		return nil, utils.RichError(codes.Unavailable, "CRANE_CALL_FAILED", err.Error())
+		return nil, utils.CraneCallError(err)
+	}
+	if !response.GetOk() {
+		return nil, utils.CraneReplyError("")
 	}
 	taskInfoList := response.GetTaskInfoList()
 	if len(taskInfoList) == 0 {
 		message := fmt.Sprintf("Task #%d was not found in crane.", in.JobId)
 		return nil, utils.RichError(codes.NotFound, "JOB_NOT_FOUND", message)
 	}
-	if response.GetOk() {
-		for _, taskInfo := range taskInfoList {
-			timeLimit := taskInfo.GetTimeLimit()
-			seconds = uint64(timeLimit.GetSeconds())
-		}
-		return &protos.QueryJobTimeLimitResponse{TimeLimitMinutes: seconds / 60}, nil
+	for _, taskInfo := range taskInfoList {
+		timeLimit := taskInfo.GetTimeLimit()
+		seconds = uint64(timeLimit.GetSeconds())
 	}
-	return nil, utils.RichError(codes.Internal, "CRANE_INTERNAL_ERROR", "Get job timelimit failed.")
+	return &protos.QueryJobTimeLimitResponse{TimeLimitMinutes: seconds / 60}, nil
 }
 
 func (s *serverJob) ChangeJobTimeLimit(ctx context.Context, in *protos.ChangeJobTimeLimitRequest) (*protos.ChangeJobTimeLimitResponse, error) {
@@ -623,16 +832,18 @@ func (s *serverJob) ChangeJobTimeLimit(ctx context.Context, in *protos.ChangeJob
 		jobIdList []uint32
 		seconds   uint64
 	)
-	logger.Infof("Received request ChangeJobTimeLimit: %v", in)
 	// 查询请求体
 	jobIdList = append(jobIdList, in.JobId)
 	requestLimitTime := &craneProtos.QueryTasksInfoRequest{
 		FilterTaskIds: jobIdList,
 	}
 
-	responseLimitTime, err := stubCraneCtld.QueryTasksInfo(context.Background(), requestLimitTime)
+	responseLimitTime, err := s.stubCraneCtld.QueryTasksInfo(ctx, requestLimitTime)
 	if err != nil {
-		return nil, utils.RichError(codes.Unavailable, "CRANE_CALL_FAILED", err.Error())
+		return nil, utils.CraneCallError(err)
+	}
+	if !responseLimitTime.GetOk() {
+		return nil, utils.CraneReplyError("")
 	}
 
 	taskInfoList := responseLimitTime.GetTaskInfoList()
@@ -640,17 +851,15 @@ func (s *serverJob) ChangeJobTimeLimit(ctx context.Context, in *protos.ChangeJob
 		message := fmt.Sprintf("Task #%d was not found in crane.", in.JobId)
 		return nil, utils.RichError(codes.NotFound, "JOB_NOT_FOUND", message)
 	}
-	if responseLimitTime.GetOk() {
-		for _, taskInfo := range taskInfoList {
-			timeLimit := taskInfo.GetTimeLimit()
-			seconds = uint64(timeLimit.GetSeconds())
-		}
+	for _, taskInfo := range taskInfoList {
+		timeLimit := taskInfo.GetTimeLimit()
+		seconds = uint64(timeLimit.GetSeconds())
 	}
 
 	// 这个地方需要做校验，如果小于0的话直接返回
 	if in.DeltaMinutes*60+int64(seconds) <= 0 {
 		// 直接返回
-		return nil, utils.RichError(codes.Unavailable, "CRANE_CALL_FAILED", "Time limit should be greater than 0.")
+		return nil, utils.RichError(codes.InvalidArgument, "INVALID_TIME_LIMIT", "Time limit should be greater than 0.")
 	}
 	// 修改时长限制的请求体
 	request := &craneProtos.ModifyTaskRequest{
@@ -659,378 +868,260 @@ func (s *serverJob) ChangeJobTimeLimit(ctx context.Context, in *protos.ChangeJob
 			TimeLimitSeconds: in.DeltaMinutes*60 + int64(seconds),
 		},
 	}
-	response, err := stubCraneCtld.ModifyTask(context.Background(), request)
+	response, err := s.stubCraneCtld.ModifyTask(ctx, request)
 	if err != nil {
-		return nil, utils.RichError(codes.Unavailable, "CRANE_CALL_FAILED", err.Error())
+		return nil, utils.CraneCallError(err)
 	}
 	if !response.GetOk() {
-		return nil, utils.RichError(codes.NotFound, "JOB_NOT_FOUND", response.GetReason())
+		return nil, utils.CraneReplyError(response.GetReason())
 	}
 	return &protos.ChangeJobTimeLimitResponse{}, nil
 }
 
 func (s *serverJob) GetJobById(ctx context.Context, in *protos.GetJobByIdRequest) (*protos.GetJobByIdResponse, error) {
-	var (
-		elapsedSeconds int64
-		state          string
-		reason         string
-	)
-	logger.Infof("Received request GetJobById: %v", in)
 	// 请求体
 	request := &craneProtos.QueryTasksInfoRequest{
 		FilterTaskIds:               []uint32{uint32(in.JobId)},
 		OptionIncludeCompletedTasks: true,
 	}
-	response, err := stubCraneCtld.QueryTasksInfo(context.Background(), request)
+	response, err := s.stubCraneCtld.QueryTasksInfo(ctx, request)
 	if err != nil {
-		return nil, utils.RichError(codes.Unavailable, "CRANE_CALL_FAILED", err.Error())
+		return nil, utils.CraneCallError(err)
 	}
 	if !response.GetOk() {
-		return nil, utils.RichError(codes.Internal, "CRANE_INTERNAL_ERROR", "Crane service internal error.")
+		return nil, utils.CraneReplyError("")
 	}
 	if len(response.GetTaskInfoList()) == 0 {
 		return nil, utils.RichError(codes.NotFound, "JOB_NOT_FOUND", "The job not found in crane.")
 	}
-	// 获取作业信息
-	TaskInfoList := response.GetTaskInfoList()[0]
-	if TaskInfoList.GetStatus() == craneProtos.TaskStatus_Running {
-		elapsedSeconds = time.Now().Unix() - TaskInfoList.GetStartTime().Seconds
-	} else if TaskInfoList.GetStatus() == craneProtos.TaskStatus_Pending {
-		elapsedSeconds = 0
+	// 与GetJobs使用相同的转换，保证两个接口返回的作业信息一致
+	jobInfo := taskToJobInfo(response.GetTaskInfoList()[0])
+	return &protos.GetJobByIdResponse{Job: selectJobFields(jobInfo, in.Fields)}, nil
+}
+
+func (s *serverJob) GetJobs(ctx context.Context, in *protos.GetJobsRequest) (*protos.GetJobsResponse, error) {
+	var (
+		jobsInfo []*protos.JobInfo
+		totalNum uint32
+	)
+	// 在查询作业之前检查排序字段
+	if in.Sort != nil {
+		if err := utils.CheckJobSortField(in.Sort.GetField()); err != nil {
+			return nil, err
+		}
 	}
-	// 获取作业时长
-	// elapsedSeconds = TaskInfoList.GetEndTime().Seconds - TaskInfoList.GetStartTime().Seconds
-	if TaskInfoList.GetStatus() == craneProtos.TaskStatus_Running {
-		elapsedSeconds = time.Now().Unix() - TaskInfoList.GetStartTime().Seconds
-	} else if TaskInfoList.GetStatus() == craneProtos.TaskStatus_Pending {
-		elapsedSeconds = 0
+	// 最多从CraneCtld查询MaxJobsPerRequest个作业，多查询一个用于判断匹配的作业是否超过限制
+	maxJobs := adapterConfig.Jobs.MaxJobsPerRequest
+	numLimit := maxJobs
+	if numLimit < math.MaxUint32 {
+		numLimit++
+	}
+	request := utils.NewTasksQuery(numLimit).Filter(in.Filter).Build()
+	response, err := s.stubCraneCtld.QueryTasksInfo(ctx, request)
+	if err != nil {
+		return nil, utils.CraneCallError(err)
+	}
+	if !response.GetOk() {
+		return nil, utils.CraneReplyError("")
+	}
+	tasks := response.GetTaskInfoList()
+	jobName := in.GetFilter().GetJobName()
+	if uint64(len(tasks)) > uint64(maxJobs) {
+		// 排序、不分页和按作业名筛选的请求需要所有匹配的作业，要求SCOW分页或缩小筛选范围
+		if in.Sort != nil || in.GetPageInfo().GetPageSize() == 0 || jobName != "" {
+			message := fmt.Sprintf("More than %d jobs match the filter. Use pagination without sorting or narrow the filter.", maxJobs)
+			return nil, utils.RichError(codes.ResourceExhausted, "TOO_MANY_JOBS", message)
+		}
+		// 分页请求只在前MaxJobsPerRequest个作业中分页，TotalCount也以此为上限
+		tasks = tasks[:maxJobs]
+	}
+	// CraneCtld只支持按作业名精确匹配，按子串筛选在适配器中进行
+	if jobName != "" {
+		tasks = filterTasksByName(tasks, jobName)
+	}
+	totalNum = uint32(len(tasks))
+	if len(tasks) == 0 {
+		return &protos.GetJobsResponse{Jobs: jobsInfo, TotalCount: &totalNum}, nil
+	}
+	pageStart, pageEnd := utils.PageRange(len(tasks), in.GetPageInfo().GetPage(), in.GetPageInfo().GetPageSize())
+	// 这里进行排序
+	if in.Sort != nil {
+		// 排序需要所有匹配的作业，排序后再分页
+		for _, task := range tasks {
+			jobsInfo = append(jobsInfo, taskToJobInfo(task))
+		}
+		if err := utils.SortJobInfo(in.Sort.GetField(), in.Sort.GetOrder(), jobsInfo); err != nil {
+			return nil, err
+		}
+		jobsInfo = jobsInfo[pageStart:pageEnd]
 	} else {
-		elapsedSeconds = TaskInfoList.GetEndTime().Seconds - TaskInfoList.GetStartTime().Seconds
+		// 不排序时只转换当前页的作业
+		for _, task := range tasks[pageStart:pageEnd] {
+			jobsInfo = append(jobsInfo, taskToJobInfo(task))
+		}
+	}
+	for i, job := range jobsInfo {
+		jobsInfo[i] = selectJobFields(job, in.Fields)
+	}
+	return &protos.GetJobsResponse{Jobs: jobsInfo, TotalCount: &totalNum}, nil
+}
+
+// 保留作业名包含jobName的作业
+func filterTasksByName(tasks []*craneProtos.TaskInfo, jobName string) []*craneProtos.TaskInfo {
+	var filteredTasks []*craneProtos.TaskInfo
+	for _, task := range tasks {
+		if strings.Contains(task.GetName(), jobName) {
+			filteredTasks = append(filteredTasks, task)
+		}
 	}
+	return filteredTasks
+}
 
-	// 获取cpu核分配数
-	// cpusAlloc := TaskInfoList.GetAllocCpus()
-	cpusAlloc := TaskInfoList.GetAllocCpu()
+// 将CraneCtld返回的作业信息转换为SCOW的作业信息
+func taskToJobInfo(job *craneProtos.TaskInfo) *protos.JobInfo {
+	var elapsedSeconds int64
+	var state string
+	var reason string = "no reason"
+	var nodeNum int32
+	var endTime *timestamppb.Timestamp
+	gpusAlloc := taskGpusAlloc(job)
+	memAllocMb := taskMemAllocMb(job)
+	if job.GetStatus() == craneProtos.TaskStatus_Running {
+		elapsedSeconds = time.Now().Unix() - job.GetStartTime().Seconds
+	} else if job.GetStatus() == craneProtos.TaskStatus_Pending {
+		elapsedSeconds = 0
+	} else {
+		elapsedSeconds = job.GetEndTime().Seconds - job.GetStartTime().Seconds
+	}
+	// cpusAlloc := job.GetAllocCpus()
+	cpusAlloc := job.GetAllocCpu()
 	cpusAllocInt32 := int32(cpusAlloc)
-	// 获取节点列表
-	nodeList := TaskInfoList.GetCranedList()
+	nodeList := job.GetCranedList()
 
-	if TaskInfoList.GetStatus().String() == "Completed" {
+	if job.GetStatus().String() == "Completed" {
 		state = "COMPLETED"
 		reason = "ENDED"
-	} else if TaskInfoList.GetStatus().String() == "Failed" {
+		endTime = job.GetEndTime()
+	} else if job.GetStatus().String() == "Failed" {
 		state = "FAILED"
 		reason = "ENDED"
-	} else if TaskInfoList.GetStatus().String() == "Cancelled" {
+		endTime = job.GetEndTime()
+	} else if job.GetStatus().String() == "Cancelled" {
 		state = "CANCELLED"
 		reason = "ENDED"
-	} else if TaskInfoList.GetStatus().String() == "Running" {
+		endTime = job.GetEndTime()
+	} else if job.GetStatus().String() == "Running" {
 		state = "RUNNING"
 		reason = "Running"
-	} else if TaskInfoList.GetStatus().String() == "Pending" {
+	} else if job.GetStatus().String() == "Pending" {
 		state = "PENDING"
 		reason = "Pending"
+	} else if job.GetStatus().String() == "ExceedTimeLimit" {
+		state = "TIMEOUT"
+		reason = "Timeout"
+		endTime = job.GetEndTime()
+	} else {
+		state = "IVALID"
+		reason = "Ivalid"
+	}
+	nodeNum = int32(job.GetNodeNum())
+	return &protos.JobInfo{
+		JobId:            job.GetTaskId(),
+		Name:             job.GetName(),
+		Account:          job.GetAccount(),
+		User:             job.GetUsername(),
+		Partition:        job.GetPartition(),
+		StartTime:        job.GetStartTime(),
+		EndTime:          endTime,
+		NodesAlloc:       &nodeNum,
+		TimeLimitMinutes: job.GetTimeLimit().Seconds / 60,
+		WorkingDirectory: job.GetCwd(),
+		State:            state,
+		NodeList:         &nodeList,
+		CpusAlloc:        &cpusAllocInt32,
+		ElapsedSeconds:   &elapsedSeconds,
+		Qos:              job.GetQos(),
+		Reason:           &reason,
+		SubmitTime:       job.GetSubmitTime(),
+		GpusAlloc:        &gpusAlloc,
+		MemAllocMb:       &memAllocMb,
 	}
+}
+
+// 作业分配的内存，单位为MB
+// CraneCtld返回的ResView是作业在所有节点上分配的资源之和(排队的作业为每个节点的请求乘以节点数)，不需要再乘以节点数
+func taskMemAllocMb(task *craneProtos.TaskInfo) int64 {
+	return int64(task.GetResView().GetAllocatableRes().GetMemoryLimitBytes() / (1024 * 1024))
+}
 
-	if len(in.Fields) == 0 {
-		jobInfo := &protos.JobInfo{
-			JobId:            TaskInfoList.GetTaskId(),
-			Name:             TaskInfoList.GetName(),
-			Account:          TaskInfoList.GetAccount(),
-			User:             TaskInfoList.GetUsername(),
-			Partition:        TaskInfoList.GetPartition(),
-			NodeList:         &nodeList,
-			StartTime:        TaskInfoList.GetStartTime(),
-			EndTime:          TaskInfoList.GetEndTime(),
-			TimeLimitMinutes: TaskInfoList.GetTimeLimit().Seconds / 60, // 转换成分钟数
-			WorkingDirectory: TaskInfoList.GetCwd(),
-			CpusAlloc:        &cpusAllocInt32,
-			State:            state,
-			ElapsedSeconds:   &elapsedSeconds,
-			Reason:           &reason,
-			Qos:              TaskInfoList.GetQos(),
-			SubmitTime:       TaskInfoList.GetStartTime(),
+// 作业分配的GPU数，设备按型号分配时累加各型号的数量，否则使用不区分型号的数量
+// DeviceMap中还可能有NPU等其他设备，只统计名称为gpu的设备
+func taskGpusAlloc(task *craneProtos.TaskInfo) int32 {
+	var count uint64
+	for name, typeCount := range task.GetResView().GetDeviceMap().GetNameTypeMap() {
+		if !strings.EqualFold(name, "gpu") {
+			continue
 		}
-		return &protos.GetJobByIdResponse{Job: jobInfo}, nil
+		if len(typeCount.GetTypeCountMap()) == 0 {
+			count += typeCount.GetTotal()
+			continue
+		}
+		for _, typeNum := range typeCount.GetTypeCountMap() {
+			count += typeNum
+		}
+	}
+	return int32(count)
+}
+
+// 只保留SCOW请求的字段，fields为空时返回全部字段
+func selectJobFields(job *protos.JobInfo, fields []string) *protos.JobInfo {
+	if len(fields) == 0 {
+		return job
 	}
-	jobInfo := &protos.JobInfo{}
-	for _, field := range in.Fields {
+	subJobInfo := &protos.JobInfo{}
+	for _, field := range fields {
 		switch field {
 		case "job_id":
-			jobInfo.JobId = TaskInfoList.GetTaskId()
+			subJobInfo.JobId = job.JobId
 		case "name":
-			jobInfo.Name = TaskInfoList.GetName()
+			subJobInfo.Name = job.Name
 		case "account":
-			jobInfo.Account = TaskInfoList.GetAccount()
+			subJobInfo.Account = job.Account
 		case "user":
-			jobInfo.User = TaskInfoList.GetUsername()
+			subJobInfo.User = job.User
 		case "partition":
-			jobInfo.Partition = TaskInfoList.GetPartition()
+			subJobInfo.Partition = job.Partition
 		case "node_list":
-			jobInfo.NodeList = &nodeList
+			subJobInfo.NodeList = job.NodeList
 		case "start_time":
-			jobInfo.StartTime = TaskInfoList.GetStartTime()
+			subJobInfo.StartTime = job.StartTime
 		case "end_time":
-			jobInfo.EndTime = TaskInfoList.GetEndTime()
+			subJobInfo.EndTime = job.EndTime
 		case "time_limit_minutes":
-			jobInfo.TimeLimitMinutes = TaskInfoList.GetTimeLimit().Seconds / 60
+			subJobInfo.TimeLimitMinutes = job.TimeLimitMinutes
 		case "working_directory":
-			jobInfo.WorkingDirectory = TaskInfoList.GetCwd()
+			subJobInfo.WorkingDirectory = job.WorkingDirectory
 		case "cpus_alloc":
-			jobInfo.CpusAlloc = &cpusAllocInt32
+			subJobInfo.CpusAlloc = job.CpusAlloc
 		case "state":
-			jobInfo.State = state
+			subJobInfo.State = job.State
 		case "elapsed_seconds":
-			jobInfo.ElapsedSeconds = &elapsedSeconds
-		case "reason":
-			jobInfo.Reason = &reason
+			subJobInfo.ElapsedSeconds = job.ElapsedSeconds
 		case "qos":
-			jobInfo.Qos = TaskInfoList.GetQos()
+			subJobInfo.Qos = job.Qos
 		case "submit_time":
-			jobInfo.SubmitTime = TaskInfoList.GetStartTime()
-		}
-	}
-	return &protos.GetJobByIdResponse{Job: jobInfo}, nil
-}
-
-func (s *serverJob) GetJobs(ctx context.Context, in *protos.GetJobsRequest) (*protos.GetJobsResponse, error) {
-	var (
-		startTimeFilter int64
-		endTimeFilter   int64
-		statesList      []craneProtos.TaskStatus
-		accountList     []string
-		request         *craneProtos.QueryTasksInfoRequest
-		jobsInfo        []*protos.JobInfo
-		totalNum        uint32
-		// submitTimeTimestamp *timestamppb.Timestamp
-	)
-	logger.Infof("Received request GetJobs: %v", in)
-
-	if in.Filter != nil {
-		if in.Filter.EndTime != nil {
-			startTimeFilter = in.Filter.EndTime.StartTime.GetSeconds()
-			endTimeFilter = in.Filter.EndTime.EndTime.GetSeconds()
-			statesList = utils.GetCraneStatesList(in.Filter.States)
-			if startTimeFilter == 0 && endTimeFilter != 0 {
-				// endTimeProto := timestamppb.New(time.Unix(endTimeFilter, 0))
-				// 新增endTimeInterval 代码
-				endTimeInterval := &craneProtos.TimeInterval{
-					UpperBound: timestamppb.New(time.Unix(endTimeFilter, 0)), // 设置结束时间的时间戳
-				}
-
-				// UpperBound 表示右区间
-				// LowerBound 表示左区间
-
-				request = &craneProtos.QueryTasksInfoRequest{
-					FilterTaskStates:            statesList,
-					FilterUsers:                 in.Filter.Users,
-					FilterAccounts:              accountList,
-					FilterEndTimeInterval:       endTimeInterval,
-					OptionIncludeCompletedTasks: true,
-					NumLimit:                    99999999,
-				}
-			} else if startTimeFilter != 0 && endTimeFilter != 0 {
-				endTimeInterval := &craneProtos.TimeInterval{
-					LowerBound: timestamppb.New(time.Unix(startTimeFilter, 0)),
-					UpperBound: timestamppb.New(time.Unix(endTimeFilter, 0)),
-				}
-
-				request = &craneProtos.QueryTasksInfoRequest{
-					FilterTaskStates:            statesList,
-					FilterUsers:                 in.Filter.Users,
-					FilterAccounts:              accountList,
-					FilterEndTimeInterval:       endTimeInterval,
-					OptionIncludeCompletedTasks: true,
-					NumLimit:                    99999999,
-				}
-			} else if startTimeFilter != 0 && endTimeFilter == 0 {
-				// startTimeProto := timestamppb.New(time.Unix(startTimeFilter, 0))
-				endTimeInterval := &craneProtos.TimeInterval{
-					LowerBound: timestamppb.New(time.Unix(startTimeFilter, 0)),
-				}
-				request = &craneProtos.QueryTasksInfoRequest{
-					FilterTaskStates:            statesList,
-					FilterUsers:                 in.Filter.Users,
-					FilterAccounts:              accountList,
-					FilterEndTimeInterval:       endTimeInterval,
-					OptionIncludeCompletedTasks: true,
-					NumLimit:                    99999999,
-				}
-			} else {
-				request = &craneProtos.QueryTasksInfoRequest{
-					FilterTaskStates:            statesList,
-					FilterUsers:                 in.Filter.Users,
-					FilterAccounts:              accountList,
-					OptionIncludeCompletedTasks: true,
-					NumLimit:                    99999999,
-				}
-			}
-		} else {
-			statesList = utils.GetCraneStatesList(in.Filter.States)
-			request = &craneProtos.QueryTasksInfoRequest{
-				FilterTaskStates:            statesList,
-				FilterUsers:                 in.Filter.Users,
-				FilterAccounts:              accountList,
-				OptionIncludeCompletedTasks: true,
-				NumLimit:                    99999999,
-			}
-		}
-	} else {
-		// 没有筛选条件的请求体
-		request = &craneProtos.QueryTasksInfoRequest{
-			OptionIncludeCompletedTasks: true,
-			NumLimit:                    99999999,
-		}
-	}
-	response, err := stubCraneCtld.QueryTasksInfo(context.Background(), request)
-	if err != nil {
-		return nil, utils.RichError(codes.Unavailable, "CRANE_CALL_FAILED", err.Error())
-	}
-	if !response.GetOk() {
-		return nil, utils.RichError(codes.Internal, "CRANE_INTERNAL_ERROR", "Crane service internal error.")
-	}
-	if len(response.GetTaskInfoList()) == 0 {
-		totalNum = uint32(len(response.GetTaskInfoList()))
-		return &protos.GetJobsResponse{Jobs: jobsInfo, TotalCount: &totalNum}, nil
-	}
-	totalNum = uint32(len(response.GetTaskInfoList()))
-	for _, job := range response.GetTaskInfoList() {
-		var elapsedSeconds int64
-		var state string
-		var reason string = "no reason"
-		var nodeNum int32
-		var endTime *timestamppb.Timestamp
-		var gpusAlloc int32 = 0
-		var memAllocMb int64 = 0
-		// var endtime *timestamppb.Timestamp
-		if job.GetStatus() == craneProtos.TaskStatus_Running {
-			elapsedSeconds = time.Now().Unix() - job.GetStartTime().Seconds
-		} else if job.GetStatus() == craneProtos.TaskStatus_Pending {
-			elapsedSeconds = 0
-		} else {
-			elapsedSeconds = job.GetEndTime().Seconds - job.GetStartTime().Seconds
-		}
-		// cpusAlloc := job.GetAllocCpus()
-		cpusAlloc := job.GetAllocCpu()
-		cpusAllocInt32 := int32(cpusAlloc)
-		nodeList := job.GetCranedList()
-
-		if job.GetStatus().String() == "Completed" {
-			state = "COMPLETED"
-			reason = "ENDED"
-			endTime = job.GetEndTime()
-		} else if job.GetStatus().String() == "Failed" {
-			state = "FAILED"
-			reason = "ENDED"
-			endTime = job.GetEndTime()
-		} else if job.GetStatus().String() == "Cancelled" {
-			state = "CANCELLED"
-			reason = "ENDED"
-			endTime = job.GetEndTime()
-		} else if job.GetStatus().String() == "Running" {
-			state = "RUNNING"
-			reason = "Running"
-		} else if job.GetStatus().String() == "Pending" {
-			state = "PENDING"
-			reason = "Pending"
-		} else if job.GetStatus().String() == "ExceedTimeLimit" {
-			state = "TIMEOUT"
-			reason = "Timeout"
-		} else {
-			state = "IVALID"
-			reason = "Ivalid"
-		}
-		nodeNum = int32(job.GetNodeNum())
-		if len(in.Fields) == 0 {
-			jobsInfo = append(jobsInfo, &protos.JobInfo{
-				JobId:            job.GetTaskId(),
-				Name:             job.GetName(),
-				Account:          job.GetAccount(),
-				User:             job.GetUsername(),
-				Partition:        job.GetPartition(),
-				StartTime:        job.GetStartTime(),
-				EndTime:          endTime,
-				NodesAlloc:       &nodeNum,
-				TimeLimitMinutes: job.GetTimeLimit().Seconds / 60,
-				WorkingDirectory: job.GetCwd(),
-				State:            state,
-				NodeList:         &nodeList,
-				CpusAlloc:        &cpusAllocInt32,
-				ElapsedSeconds:   &elapsedSeconds,
-				Qos:              job.GetQos(),
-				Reason:           &reason,
-				SubmitTime:       job.GetStartTime(), // 没有提交时间，用开始时间来代替
-				GpusAlloc:        &gpusAlloc,
-				MemAllocMb:       &memAllocMb,
-			})
-		} else {
-			subJobInfo := &protos.JobInfo{}
-			for _, field := range in.Fields {
-				switch field {
-				case "job_id":
-					subJobInfo.JobId = job.GetTaskId()
-				case "name":
-					subJobInfo.Name = job.GetName()
-				case "account":
-					subJobInfo.Account = job.GetAccount()
-				case "user":
-					subJobInfo.User = job.GetUsername()
-				case "partition":
-					subJobInfo.Partition = job.GetPartition()
-				case "node_list":
-					subJobInfo.NodeList = &nodeList
-				case "start_time":
-					subJobInfo.StartTime = job.GetStartTime()
-				case "end_time":
-					subJobInfo.EndTime = endTime
-				case "time_limit_minutes":
-					subJobInfo.TimeLimitMinutes = job.GetTimeLimit().Seconds / 60
-				case "working_directory":
-					subJobInfo.WorkingDirectory = job.GetCwd()
-				case "cpus_alloc":
-					subJobInfo.CpusAlloc = &cpusAllocInt32
-				case "state":
-					subJobInfo.State = state
-				case "elapsed_seconds":
-					subJobInfo.ElapsedSeconds = &elapsedSeconds
-				case "qos":
-					subJobInfo.Qos = job.GetQos()
-				case "submit_time":
-					subJobInfo.SubmitTime = job.GetStartTime() // 没有提交时间
-				case "reason":
-					subJobInfo.Reason = &reason
-				case "nodes_alloc":
-					subJobInfo.NodesAlloc = &nodeNum
-				case "gpus_alloc":
-					subJobInfo.GpusAlloc = &gpusAlloc
-				case "mem_alloc_mb":
-					subJobInfo.MemAllocMb = &memAllocMb
-				}
-			}
-			jobsInfo = append(jobsInfo, subJobInfo)
-		}
-	}
-	// 这里进行排序
-	if in.Sort != nil && len(jobsInfo) != 0 {
-		// 这里把slurm适配器的代码拿过来就可以了
-		var sortKey string
-		if in.Sort.GetField() == "" {
-			sortKey = "JobId" // 默认jobid进行排序
-		} else {
-			sortKey = in.Sort.GetField()
-			// 字段转换成首字母大写的字符串
-			words := strings.Split(sortKey, "_")
-			for i := 0; i < len(words); i++ {
-				words[i] = strings.Title(words[i])
-			}
-			sortKey = strings.Join(words, "")
+			subJobInfo.SubmitTime = job.SubmitTime
+		case "reason":
+			subJobInfo.Reason = job.Reason
+		case "nodes_alloc":
+			subJobInfo.NodesAlloc = job.NodesAlloc
+		case "gpus_alloc":
+			subJobInfo.GpusAlloc = job.GpusAlloc
+		case "mem_alloc_mb":
+			subJobInfo.MemAllocMb = job.MemAllocMb
 		}
-		sortOrder := in.Sort.GetOrder().String()
-		sortJobinfo := utils.SortJobInfo(sortKey, sortOrder, jobsInfo)
-		return &protos.GetJobsResponse{Jobs: sortJobinfo, TotalCount: &totalNum}, nil
 	}
-	logger.Infof("%v", jobsInfo)
-	return &protos.GetJobsResponse{Jobs: jobsInfo, TotalCount: &totalNum}, nil
+	return subJobInfo
 }
 
 func (s *serverJob) SubmitJob(ctx context.Context, in *protos.SubmitJobRequest) (*protos.SubmitJobResponse, error) {
@@ -1043,8 +1134,6 @@ func (s *serverJob) SubmitJob(ctx context.Context, in *protos.SubmitJobRequest)
 		scriptString    = "#!/bin/bash\n"
 	)
 
-	logger.Infof("Received request SubmitJob: %v", in)
-
 	if in.Stdout != nil {
 		stdout = *in.Stdout
 	} else { // 可选参数没传的情况
@@ -1061,7 +1150,10 @@ func (s *serverJob) SubmitJob(ctx context.Context, in *protos.SubmitJobRequest)
 
 	isAbsolute := filepath.IsAbs(in.WorkingDirectory)
 	if !isAbsolute {
-		homedirTemp, _ := utils.GetUserHomedir(in.UserId)
+		homedirTemp, err := utils.GetUserHomedir(in.UserId)
+		if err != nil {
+			return nil, utils.RichError(codes.InvalidArgument, "USER_NOT_FOUND", fmt.Sprintf("Cannot get the home directory of user %s: %v", in.UserId, err))
+		}
 		homedir = homedirTemp + "/" + in.WorkingDirectory
 	} else {
 		homedir = in.WorkingDirectory
@@ -1105,54 +1197,38 @@ func (s *serverJob) SubmitJob(ctx context.Context, in *protos.SubmitJobRequest)
 	scriptString += "#CBATCH " + "--get-user-env" + "\n"
 	scriptString += in.Script
 
-	// 将这个保存成一个脚本文件，通过脚本文件进行提交
-	// 生成一个随机的文件名
-	var letters = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
-	b := make([]rune, 10)
-	for i := range b {
-		b[i] = letters[rand.Intn(len(letters))]
+	var jobId uint32
+	var err error
+	// 额外的作业选项只能由cbatch解析，这种情况下仍然通过cbatch提交
+	if adapterConfig.Features.SubmitMode == utils.SubmitModeCbatch || len(in.ExtraOptions) != 0 {
+		jobId, err = submitScriptByCbatch(ctx, scriptString, in.UserId)
+	} else {
+		jobId, err = s.submitJobByGrpc(ctx, in, homedir, scriptString)
 	}
-	filePath := "/tmp" + "/" + string(b) + ".sh" // 生成的脚本存放路径
-	file, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE, 0777)
 	if err != nil {
-		return nil, utils.RichError(codes.Aborted, "CREATE_SCRIPT_FAILED", "Create submit script failed.")
+		return nil, err
 	}
-	defer file.Close()
-	writer := bufio.NewWriter(file)
-	writer.WriteString(scriptString)
-	writer.Flush()
+	return &protos.SubmitJobResponse{JobId: jobId, GeneratedScript: scriptString}, nil
+}
 
-	submitResult, err := utils.LocalSubmitJob(filePath, in.UserId)
-	os.Remove(filePath) // 删除掉提交脚本
+func (s *serverJob) SubmitScriptAsJob(ctx context.Context, in *protos.SubmitScriptAsJobRequest) (*protos.SubmitScriptAsJobResponse, error) {
+	// 获取传过来的文件内容
+	scriptString := in.Script
+	// 脚本中没有指定工作目录时，使用脚本文件所在目录作为工作目录
+	if in.ScriptFileFullPath != nil && !utils.HasChdirDirective(scriptString) {
+		chdirString := fmt.Sprintf("#CBATCH --chdir %s", filepath.Dir(*in.ScriptFileFullPath))
+		scriptString = utils.InsertDirective(scriptString, chdirString)
+	}
+	// 脚本中的#CBATCH指令需要由cbatch解析，因此脚本作业始终通过cbatch提交
+	jobId, err := submitScriptByCbatch(ctx, scriptString, in.UserId)
 	if err != nil {
-		return nil, utils.RichError(codes.Internal, "CRANE_INTERNAL_ERROR", submitResult)
+		return nil, err
 	}
-	responseList := strings.Split(strings.TrimSpace(string(submitResult)), " ")
-	jobIdString := responseList[len(responseList)-1]
-
-	jobId1, _ := strconv.Atoi(jobIdString[:len(jobIdString)-1])
-
-	return &protos.SubmitJobResponse{JobId: uint32(jobId1), GeneratedScript: scriptString}, nil
+	return &protos.SubmitScriptAsJobResponse{JobId: jobId, GeneratedScript: scriptString}, nil
 }
 
-func SubmitScriptAsJob(ctx context.Context, in *protos.SubmitScriptAsJobRequest) (*protos.SubmitScriptAsJobResponse, error) {
-	// 获取传过来的文件内容
-	logger.Infof("Received request SubmitScriptAsJob: %v", in)
-	// 具体的提交逻辑
-	updateScript := "#!/bin/bash\n"
-	trimmedScript := strings.TrimLeft(in.Script, "\n") // 去除最前面的空行
-	// 通过换行符 "\n" 分割字符串
-	checkBool1 := strings.Contains(trimmedScript, "--chdir")
-	checkBool2 := strings.Contains(trimmedScript, " -D ")
-	if !checkBool1 && !checkBool2 {
-		chdirString := fmt.Sprintf("#SBATCH --chdir=%s\n", *in.ScriptFileFullPath) // 这个地方需要更新protos文件后再处理
-		updateScript = updateScript + chdirString
-		for _, value := range strings.Split(trimmedScript, "\n")[1:] {
-			updateScript = updateScript + value + "\n"
-		}
-		in.Script = updateScript
-	}
-	// 将这个保存成一个脚本文件，通过脚本文件进行提交
+// 将脚本保存成临时文件，以用户身份通过cbatch提交
+func submitScriptByCbatch(ctx context.Context, scriptString string, userId string) (uint32, error) {
 	// 生成一个随机的文件名
 	var letters = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
 	b := make([]rune, 10)
@@ -1162,70 +1238,293 @@ func SubmitScriptAsJob(ctx context.Context, in *protos.SubmitScriptAsJobRequest)
 	filePath := "/tmp" + "/" + string(b) + ".sh" // 生成的脚本存放路径
 	file, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE, 0777)
 	if err != nil {
-		return nil, utils.RichError(codes.Aborted, "CREATE_SCRIPT_FAILED", "Create submit script failed.")
+		return 0, utils.RichError(codes.Aborted, "CREATE_SCRIPT_FAILED", "Create submit script failed.")
 	}
 	defer file.Close()
 	writer := bufio.NewWriter(file)
-	writer.WriteString(in.Script)
+	writer.WriteString(scriptString)
 	writer.Flush()
 
-	submitResult, err := utils.LocalSubmitJob(filePath, in.UserId)
+	submitResult, err := localSubmitJob(ctx, filePath, userId)
 	os.Remove(filePath) // 删除生成的提交脚本
+	if ctx.Err() != nil {
+		// 请求超时或被取消时cbatch已被终止
+		return 0, status.FromContextError(ctx.Err()).Err()
+	}
+	if err != nil {
+		return 0, submitError(submitResult)
+	}
+	jobId, err := utils.ParseCbatchJobId(submitResult)
+	if err != nil {
+		return 0, utils.RichError(codes.Internal, "SBATCH_FAILED", err.Error())
+	}
+	return jobId, nil
+}
+
+// 根据作业参数构造TaskToCtld，以用户的uid直接调用CraneCtld提交作业
+func (s *serverJob) submitJobByGrpc(ctx context.Context, in *protos.SubmitJobRequest, workingDirectory string, scriptString string) (uint32, error) {
+	uid, err := utils.GetUidByUserName(in.UserId)
+	if err != nil {
+		return 0, utils.RichError(codes.NotFound, "USER_NOT_FOUND", "The user is not exists.")
+	}
+	task := &craneProtos.TaskToCtld{
+		Name:          in.JobName,
+		Type:          craneProtos.TaskType_Batch,
+		Uid:           uint32(uid),
+		Account:       in.Account,
+		PartitionName: in.Partition,
+		NodeNum:       in.NodeCount,
+		NtasksPerNode: 1,
+		CpusPerTask:   float64(in.CoreCount),
+		TimeLimit:     utils.InvalidDuration(),
+		Cwd:           workingDirectory,
+		GetUserEnv:    true,
+		Resources: &craneProtos.ResourceView{
+			AllocatableRes: &craneProtos.AllocatableResource{
+				CpuCoreLimit: float64(in.CoreCount),
+			},
+		},
+		Payload: &craneProtos.TaskToCtld_BatchMeta{
+			BatchMeta: &craneProtos.BatchTaskAdditionalMeta{
+				ShScript: scriptString,
+			},
+		},
+	}
+	if in.Qos != nil {
+		task.Qos = *in.Qos
+	}
+	if in.TimeLimitMinutes != nil {
+		task.TimeLimit = durationpb.New(time.Duration(*in.TimeLimitMinutes) * time.Minute)
+	}
+	if in.MemoryMb != nil {
+		// 内存限制以节点为单位，与cbatch的--mem保持一致
+		task.Resources.AllocatableRes.MemoryLimitBytes = *in.MemoryMb * 1024 * 1024
+		task.Resources.AllocatableRes.MemorySwLimitBytes = *in.MemoryMb * 1024 * 1024
+	}
+	if in.Stdout != nil {
+		// 与cbatch的--output相同，相对路径相对于作业的工作目录
+		outputFilePattern := *in.Stdout
+		if !filepath.IsAbs(outputFilePattern) {
+			outputFilePattern = filepath.Join(workingDirectory, outputFilePattern)
+		}
+		task.GetBatchMeta().OutputFilePattern = outputFilePattern
+	}
+
+	request := &craneProtos.SubmitBatchTaskRequest{Task: task}
+	response, err := s.stubCraneCtld.SubmitBatchTask(ctx, request)
 	if err != nil {
-		return nil, utils.RichError(codes.Internal, "CRANE_INTERNAL_ERROR", submitResult)
+		return 0, utils.CraneCallError(err)
+	}
+	if !response.GetOk() {
+		return 0, submitError(response.GetReason())
+	}
+	return response.GetTaskId(), nil
+}
+
+// 提交失败时尽量给出具体的错误码，无法识别的原因仍然返回SBATCH_FAILED
+func submitError(craneReason string) error {
+	code, reason, ok := utils.TranslateCraneReason(craneReason)
+	if !ok {
+		return utils.RichError(codes.Internal, "SBATCH_FAILED", craneReason)
 	}
-	responseList := strings.Split(strings.TrimSpace(string(submitResult)), " ")
-	jobIdString := responseList[len(responseList)-1]
+	return utils.RichError(code, reason, craneReason)
+}
+
+// 注册SCOW调度器适配器接口的各个服务
+func registerServices(s *grpc.Server, stubCraneCtld utils.CraneCtldClient) {
+	protos.RegisterJobServiceServer(s, &serverJob{stubCraneCtld: stubCraneCtld})
+	protos.RegisterAccountServiceServer(s, &serverAccount{stubCraneCtld: stubCraneCtld})
+	protos.RegisterConfigServiceServer(s, &serverConfig{stubCraneCtld: stubCraneCtld})
+	protos.RegisterUserServiceServer(s, &serverUser{stubCraneCtld: stubCraneCtld})
+	protos.RegisterVersionServiceServer(s, &serverVersion{})
+	protos.RegisterAppServiceServer(s, &serverApp{})
+}
 
-	jobId1, _ := strconv.Atoi(jobIdString[:len(jobIdString)-1])
+// 需要写入审计日志的修改操作
+func auditedMethods() []string {
+	fullMethod := func(desc grpc.ServiceDesc, method string) string {
+		return "/" + desc.ServiceName + "/" + method
+	}
+	return []string{
+		fullMethod(protos.AccountService_ServiceDesc, "CreateAccount"),
+		fullMethod(protos.AccountService_ServiceDesc, "BlockAccount"),
+		fullMethod(protos.AccountService_ServiceDesc, "UnblockAccount"),
+		fullMethod(protos.UserService_ServiceDesc, "AddUserToAccount"),
+		fullMethod(protos.UserService_ServiceDesc, "RemoveUserFromAccount"),
+		fullMethod(protos.UserService_ServiceDesc, "BlockUserInAccount"),
+		fullMethod(protos.UserService_ServiceDesc, "UnblockUserInAccount"),
+		fullMethod(protos.JobService_ServiceDesc, "CancelJob"),
+		fullMethod(protos.JobService_ServiceDesc, "ChangeJobTimeLimit"),
+		fullMethod(protos.JobService_ServiceDesc, "SubmitJob"),
+		fullMethod(protos.JobService_ServiceDesc, "SubmitScriptAsJob"),
+	}
+}
 
-	return &protos.SubmitScriptAsJobResponse{JobId: uint32(jobId1)}, nil
+// 解析命令行参数，返回适配器配置文件路径以及该文件是否必须存在
+func parseConfigFlags(name string, args []string) (string, bool) {
+	flagSet := flag.NewFlagSet(name, flag.ExitOnError)
+	configPath := flagSet.String("config", utils.DefaultAdapterConfigPath, "path of the adapter config file")
+	flagSet.Parse(args)
+	// 显式指定的配置文件必须存在，默认路径下没有配置文件时使用默认配置
+	configRequired := false
+	flagSet.Visit(func(f *flag.Flag) {
+		if f.Name == "config" {
+			configRequired = true
+		}
+	})
+	return *configPath, configRequired
+}
+
+// 收到SIGHUP时重新读取配置文件中的日志级别，无需重启即可调整日志级别
+func reloadLogLevelOnSignal(configPath string, configRequired bool) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	for range signals {
+		newConfig, err := utils.ParseAdapterConfig(configPath, configRequired)
+		if err != nil {
+			logger.Errorf("Failed to reload log level: %v", err)
+			continue
+		}
+		level, err := logrus.ParseLevel(newConfig.Log.Level)
+		if err != nil {
+			logger.Errorf("Failed to reload log level: %v", err)
+			continue
+		}
+		logger.SetLevel(level)
+		logger.Infof("Log level changed to %s", level)
+	}
+}
+
+// check-config子命令：检查配置并输出所有问题，配置有误时返回非0
+func checkConfig(args []string) int {
+	adapterConfig, config, err := utils.LoadConfig(parseConfigFlags("check-config", args))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Printf("configuration OK: cluster %s, CraneCtld %s, partitions %d, listen %s\n",
+		config.ClusterName, adapterConfig.CraneCtldAddress, len(config.Partitions), adapterConfig.ListenAddress)
+	return 0
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "check-config" {
+		os.Exit(checkConfig(os.Args[2:]))
+	}
+
+	// 解析适配器配置文件和crane配置文件
+	var err error
+	configPath, configRequired := parseConfigFlags(os.Args[0], os.Args[1:])
+	adapterConfig, config, err = utils.LoadConfig(configPath, configRequired)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
 	// 创建日志实例
-	logger = logrus.New()
-	// 设置日志输出格式为JSON
-	logger.SetFormatter(&logrus.JSONFormatter{})
-	// 设置日志级别为Info
-	logger.SetLevel(logrus.InfoLevel)
-	logFile := &lumberjack.Logger{
-		Filename:   "server.log", // 日志文件路径
-		MaxSize:    10,           // 日志文件的最大大小（以MB为单位）
-		MaxBackups: 3,            // 保留的旧日志文件数量
-		MaxAge:     28,           // 保留的旧日志文件的最大天数
-		LocalTime:  true,         // 使用本地时间戳
-		Compress:   true,         // 是否压缩旧日志文件
-	}
-	logger.SetOutput(io.MultiWriter(os.Stdout, logFile))
-	defer logFile.Close()
+	var closeLog func()
+	logger, closeLog, err = utils.NewLogger(adapterConfig.Log)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to create logger: %v\n", err)
+		os.Exit(1)
+	}
+	defer closeLog()
+	go reloadLogLevelOnSignal(configPath, configRequired)
+
+	// 启用tracing时需要在创建拦截器之前初始化
+	if adapterConfig.Tracing.Enabled {
+		shutdownTracing, err := utils.InitTracing(adapterConfig.Tracing)
+		if err != nil {
+			logger.Fatalf("Failed to init tracing: %v", err)
+		}
+		defer shutdownTracing(context.Background())
+	}
 
 	// CraneCtld 客户端
-	serverAddr := fmt.Sprintf("%s:%s", config.ControlMachine, config.CraneCtldListenPort)
-	conn, err := grpc.Dial(serverAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	craneCredentials, err := utils.CraneCtldCredentials(config)
 	if err != nil {
-		log.Fatal("Cannot connect to CraneCtld: " + err.Error())
+		logger.Fatalf("Cannot load CraneCtld tls config: %v", err)
+	}
+	var dialOptions []grpc.DialOption
+	if adapterConfig.Tracing.Enabled {
+		dialOptions = append(dialOptions, grpc.WithChainUnaryInterceptor(utils.CraneCtldTracingInterceptor()))
 	}
-	defer conn.Close()
-	stubCraneCtld = craneProtos.NewCraneCtldClient(conn)
+	if adapterConfig.Metrics.Enabled {
+		dialOptions = append(dialOptions, grpc.WithChainUnaryInterceptor(utils.CraneCtldMetricsInterceptor()))
+	}
+	// 所有服务共用一个CraneCtld连接，CraneCtld不可用时自动重连
+	stubCraneCtld, err := utils.DialCraneCtld(adapterConfig.CraneCtldAddress, craneCredentials, dialOptions...)
+	if err != nil {
+		logger.Fatalf("Cannot connect to CraneCtld: %v", err)
+	}
+	defer stubCraneCtld.Close()
+	healthChecker := newHealthChecker(stubCraneCtld)
+	go healthChecker.run(context.Background())
+	go stubCraneCtld.WatchState(context.Background(), func(state connectivity.State) {
+		if state == connectivity.TransientFailure {
+			logger.Warnf("CraneCtld %s is unavailable, reconnecting", adapterConfig.CraneCtldAddress)
+		} else {
+			logger.Infof("CraneCtld connection state changed to %v", state)
+		}
+		healthChecker.checkNow()
+	})
 
-	// 监听本地8972端口
-	lis, err := net.Listen("tcp", ":8972")
+	// 监听配置的地址，默认为8972端口
+	lis, err := net.Listen("tcp", adapterConfig.ListenAddress)
 	if err != nil {
-		fmt.Printf("failed to listen: %v", err)
-		return
-	}
-	s := grpc.NewServer()                            // 创建gRPC服务器
-	protos.RegisterJobServiceServer(s, &serverJob{}) // 注册服务
-	protos.RegisterAccountServiceServer(s, &serverAccount{})
-	protos.RegisterConfigServiceServer(s, &serverConfig{})
-	protos.RegisterUserServiceServer(s, &serverUser{})
-	protos.RegisterVersionServiceServer(s, &serverVersion{})
-	protos.RegisterAppServiceServer(s, &serverApp{})
+		logger.WithError(err).Fatal("Failed to listen")
+	}
+	var interceptors []grpc.UnaryServerInterceptor
+	if adapterConfig.Tracing.Enabled {
+		interceptors = append(interceptors, utils.TracingInterceptor())
+	}
+	interceptors = append(interceptors, utils.LoggingInterceptor(logger))
+	if adapterConfig.Metrics.Enabled {
+		interceptors = append(interceptors, utils.MetricsInterceptor())
+		go func() {
+			if err := utils.ServeMetrics(adapterConfig.Metrics.ListenAddress); err != nil {
+				logger.Errorf("Metrics server stopped: %v", err)
+			}
+		}()
+	}
+	if adapterConfig.Audit.Enabled {
+		auditLogger := utils.NewAuditLogger(adapterConfig.Audit)
+		defer auditLogger.Close()
+		interceptors = append(interceptors, utils.AuditInterceptor(auditLogger, auditedMethods(), func(err error) {
+			logger.Errorf("Failed to write audit log: %v", err)
+		}))
+	}
+	if adapterConfig.Auth.Enabled {
+		// 版本、应用和健康检查服务不需要认证
+		authInterceptor, err := utils.AuthInterceptor(adapterConfig.Auth.TokenFilePath, []string{
+			protos.JobService_ServiceDesc.ServiceName,
+			protos.AccountService_ServiceDesc.ServiceName,
+			protos.UserService_ServiceDesc.ServiceName,
+			protos.ConfigService_ServiceDesc.ServiceName,
+		})
+		if err != nil {
+			logger.Fatalf("Failed to load auth tokens: %v", err)
+		}
+		interceptors = append(interceptors, authInterceptor)
+	}
+	interceptors = append(interceptors, utils.DefaultTimeoutInterceptor(adapterConfig.RequestTimeout))
+	serverOptions := []grpc.ServerOption{grpc.ChainUnaryInterceptor(interceptors...)}
+	if adapterConfig.Auth.Enabled {
+		// HMAC签名覆盖请求的原始字节
+		serverOptions = append(serverOptions, utils.RawRequestServerOptions()...)
+	}
+	if adapterConfig.Tls.Enabled {
+		tlsConfig, err := utils.NewServerTLSConfig(adapterConfig.Tls, logger)
+		if err != nil {
+			logger.Fatalf("Failed to load tls config: %v", err)
+		}
+		serverOptions = append(serverOptions, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	s := grpc.NewServer(serverOptions...) // 创建gRPC服务器
+	registerServices(s, stubCraneCtld)
+	healthpb.RegisterHealthServer(s, healthChecker.server)
 	// 启动服务
-	err = s.Serve(lis)
-	if err != nil {
-		fmt.Printf("failed to serve: %v", err)
-		return
+	if err := s.Serve(lis); err != nil {
+		logger.WithError(err).Fatal("Failed to serve")
 	}
 }
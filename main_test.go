@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"os/user"
+	"testing"
+
+	craneProtos "scow-crane-adapter/gen/crane"
+	protos "scow-crane-adapter/gen/go"
+	"scow-crane-adapter/tests/fakectld"
+	"scow-crane-adapter/utils"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestMain(m *testing.M) {
+	logger = logrus.New()
+	logger.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// 启动一个连接fake CraneCtld的适配器，返回fake和连接到适配器的客户端
+func newTestServer(t *testing.T) (*fakectld.FakeCraneCtld, *grpc.ClientConn) {
+	t.Helper()
+	fake := fakectld.New()
+	fake.AddQos("UNLIMITED", "normal", "high")
+	fake.AddPartition(&craneProtos.PartitionInfo{
+		Name:       "CPU",
+		State:      craneProtos.PartitionState_PARTITION_UP,
+		TotalNodes: 4,
+		AliveNodes: 3,
+		TotalCpu:   128,
+		AvailCpu:   64,
+		AllocCpu:   32,
+		TotalMem:   512 * 1024 * 1024 * 1024,
+	})
+	adapterConfig = utils.DefaultAdapterConfig()
+	config = &utils.Config{
+		ClusterName: "test",
+		Partitions:  []utils.Partition{{Name: "CPU"}},
+	}
+
+	lis := bufconn.Listen(1024 * 1024)
+	s := grpc.NewServer()
+	registerServices(s, fake)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("did not connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return fake, conn
+}
+
+// 测试中使用当前用户作为SCOW用户，保证用户在本机存在
+func currentUser(t *testing.T) string {
+	t.Helper()
+	u, err := user.Current()
+	if err != nil {
+		t.Fatalf("lookup current user failed: %v", err)
+	}
+	return u.Username
+}
+
+// 获取rich error中的ErrorInfo.Reason
+func errorReason(err error) string {
+	st, ok := status.FromError(err)
+	if !ok {
+		return ""
+	}
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			return info.Reason
+		}
+	}
+	return ""
+}
+
+func TestAuditedMethodsExist(t *testing.T) {
+	methods := map[string]bool{}
+	for _, desc := range []grpc.ServiceDesc{protos.AccountService_ServiceDesc, protos.UserService_ServiceDesc, protos.JobService_ServiceDesc} {
+		for _, method := range desc.Methods {
+			methods["/"+desc.ServiceName+"/"+method.MethodName] = true
+		}
+	}
+
+	for _, method := range auditedMethods() {
+		assert.True(t, methods[method], "unknown audited method %s", method)
+	}
+}
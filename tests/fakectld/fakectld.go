@@ -0,0 +1,597 @@
+// Package fakectld 提供一个保存在内存中的CraneCtld实现
+// 实现了utils.CraneCtldClient接口，用于在没有Crane集群的环境下端到端地测试适配器
+package fakectld
+
+import (
+	"context"
+	"fmt"
+	"os/user"
+	"sort"
+	"strings"
+	"sync"
+
+	craneProtos "scow-crane-adapter/gen/crane"
+	"scow-crane-adapter/utils"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var _ utils.CraneCtldClient = (*FakeCraneCtld)(nil)
+
+type FakeCraneCtld struct {
+	mu sync.Mutex
+
+	accounts   map[string]*craneProtos.AccountInfo
+	users      []*craneProtos.UserInfo // 每个用户和账户的关联关系保存为一条记录，按添加顺序排列
+	qos        []*craneProtos.QosInfo
+	partitions []*craneProtos.PartitionInfo
+	craneds    []*craneProtos.CranedInfo
+	tasks      map[uint32]*craneProtos.TaskInfo
+	submitted  map[uint32]*craneProtos.TaskToCtld
+	nextTaskId uint32
+
+	lastTasksQuery *craneProtos.QueryTasksInfoRequest // 最近一次QueryTasksInfo的请求
+
+	errs map[string]error // 按方法名注入的调用错误
+}
+
+func New() *FakeCraneCtld {
+	return &FakeCraneCtld{
+		accounts:   map[string]*craneProtos.AccountInfo{},
+		tasks:      map[uint32]*craneProtos.TaskInfo{},
+		submitted:  map[uint32]*craneProtos.TaskToCtld{},
+		nextTaskId: 1,
+		errs:       map[string]error{},
+	}
+}
+
+// 添加Qos
+func (f *FakeCraneCtld) AddQos(names ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, name := range names {
+		f.qos = append(f.qos, &craneProtos.QosInfo{Name: name})
+	}
+}
+
+// 添加计算分区
+func (f *FakeCraneCtld) AddPartition(partition *craneProtos.PartitionInfo) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.partitions = append(f.partitions, proto.Clone(partition).(*craneProtos.PartitionInfo))
+}
+
+// 添加计算节点
+func (f *FakeCraneCtld) AddCraned(hostname string, state craneProtos.CranedState, partitionNames ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.craneds = append(f.craneds, &craneProtos.CranedInfo{Hostname: hostname, State: state, PartitionNames: partitionNames})
+}
+
+// 直接添加一个作业，作业id为0时自动分配，返回作业id
+func (f *FakeCraneCtld) AddTask(task *craneProtos.TaskInfo) uint32 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	task = proto.Clone(task).(*craneProtos.TaskInfo)
+	if task.TaskId == 0 {
+		task.TaskId = f.nextTaskId
+	}
+	if task.TaskId >= f.nextTaskId {
+		f.nextTaskId = task.TaskId + 1
+	}
+	f.tasks[task.TaskId] = task
+	return task.TaskId
+}
+
+// 设置某个方法返回的调用错误，err为nil时恢复正常
+func (f *FakeCraneCtld) SetError(method string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err == nil {
+		delete(f.errs, method)
+		return
+	}
+	f.errs[method] = err
+}
+
+// 获取账户信息，账户不存在时返回nil
+func (f *FakeCraneCtld) Account(name string) *craneProtos.AccountInfo {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if account, ok := f.accounts[name]; ok {
+		return proto.Clone(account).(*craneProtos.AccountInfo)
+	}
+	return nil
+}
+
+// 获取用户和账户的关联关系，不存在时返回nil
+func (f *FakeCraneCtld) User(name string, account string) *craneProtos.UserInfo {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if index := f.findUser(name, account); index != -1 {
+		return proto.Clone(f.users[index]).(*craneProtos.UserInfo)
+	}
+	return nil
+}
+
+// 获取作业信息，作业不存在时返回nil
+func (f *FakeCraneCtld) Task(taskId uint32) *craneProtos.TaskInfo {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if task, ok := f.tasks[taskId]; ok {
+		return proto.Clone(task).(*craneProtos.TaskInfo)
+	}
+	return nil
+}
+
+// 获取最近一次QueryTasksInfo的请求，没有查询过时返回nil
+func (f *FakeCraneCtld) LastTasksQuery() *craneProtos.QueryTasksInfoRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.lastTasksQuery == nil {
+		return nil
+	}
+	return proto.Clone(f.lastTasksQuery).(*craneProtos.QueryTasksInfoRequest)
+}
+
+// 获取通过SubmitBatchTask提交的原始作业，不存在时返回nil
+func (f *FakeCraneCtld) SubmittedTask(taskId uint32) *craneProtos.TaskToCtld {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if task, ok := f.submitted[taskId]; ok {
+		return proto.Clone(task).(*craneProtos.TaskToCtld)
+	}
+	return nil
+}
+
+func (f *FakeCraneCtld) findUser(name string, account string) int {
+	for i, u := range f.users {
+		if u.Name == name && u.Account == account {
+			return i
+		}
+	}
+	return -1
+}
+
+// 返回给调用方的用户信息，和Crane一样在用户的默认账户后面加上*
+func (f *FakeCraneCtld) userView(u *craneProtos.UserInfo) *craneProtos.UserInfo {
+	view := proto.Clone(u).(*craneProtos.UserInfo)
+	for _, other := range f.users {
+		if other.Name == u.Name {
+			if other == u {
+				view.Account += "*"
+			}
+			break
+		}
+	}
+	return view
+}
+
+func (f *FakeCraneCtld) SubmitBatchTask(ctx context.Context, in *craneProtos.SubmitBatchTaskRequest, opts ...grpc.CallOption) (*craneProtos.SubmitBatchTaskReply, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.errs["SubmitBatchTask"]; err != nil {
+		return nil, err
+	}
+	task := in.GetTask()
+	fail := func(reason string) (*craneProtos.SubmitBatchTaskReply, error) {
+		return &craneProtos.SubmitBatchTaskReply{Ok: false, Payload: &craneProtos.SubmitBatchTaskReply_Reason{Reason: reason}}, nil
+	}
+	if !f.hasPartition(task.GetPartitionName()) {
+		return fail(fmt.Sprintf("Partition '%s' doesn't exist!", task.GetPartitionName()))
+	}
+	if _, ok := f.accounts[task.GetAccount()]; !ok {
+		return fail(fmt.Sprintf("Account '%s' doesn't exist!", task.GetAccount()))
+	}
+	if f.accounts[task.GetAccount()].Blocked {
+		return fail(fmt.Sprintf("The account '%s' is blocked.", task.GetAccount()))
+	}
+
+	taskId := f.nextTaskId
+	f.nextTaskId++
+	username := ""
+	if u, err := user.LookupId(fmt.Sprint(task.GetUid())); err == nil {
+		username = u.Username
+	}
+	f.submitted[taskId] = proto.Clone(task).(*craneProtos.TaskToCtld)
+	f.tasks[taskId] = &craneProtos.TaskInfo{
+		Type:       task.GetType(),
+		TaskId:     taskId,
+		Name:       task.GetName(),
+		Partition:  task.GetPartitionName(),
+		Uid:        task.GetUid(),
+		TimeLimit:  task.GetTimeLimit(),
+		SubmitTime: timestamppb.Now(),
+		Account:    task.GetAccount(),
+		NodeNum:    task.GetNodeNum(),
+		CmdLine:    task.GetCmdLine(),
+		Cwd:        task.GetCwd(),
+		Username:   username,
+		Qos:        task.GetQos(),
+		ResView:    task.GetResources(),
+		Status:     craneProtos.TaskStatus_Pending,
+	}
+	return &craneProtos.SubmitBatchTaskReply{Ok: true, Payload: &craneProtos.SubmitBatchTaskReply_TaskId{TaskId: taskId}}, nil
+}
+
+func (f *FakeCraneCtld) CancelTask(ctx context.Context, in *craneProtos.CancelTaskRequest, opts ...grpc.CallOption) (*craneProtos.CancelTaskReply, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.errs["CancelTask"]; err != nil {
+		return nil, err
+	}
+	reply := &craneProtos.CancelTaskReply{}
+	for _, taskId := range in.GetFilterTaskIds() {
+		task, ok := f.tasks[taskId]
+		if !ok || !isActive(task.Status) {
+			reply.NotCancelledTasks = append(reply.NotCancelledTasks, taskId)
+			reply.NotCancelledReasons = append(reply.NotCancelledReasons, "Task id doesn't exist!")
+			continue
+		}
+		task.Status = craneProtos.TaskStatus_Cancelled
+		task.EndTime = timestamppb.Now()
+		reply.CancelledTasks = append(reply.CancelledTasks, taskId)
+	}
+	return reply, nil
+}
+
+func (f *FakeCraneCtld) QueryPartitionInfo(ctx context.Context, in *craneProtos.QueryPartitionInfoRequest, opts ...grpc.CallOption) (*craneProtos.QueryPartitionInfoReply, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.errs["QueryPartitionInfo"]; err != nil {
+		return nil, err
+	}
+	reply := &craneProtos.QueryPartitionInfoReply{}
+	for _, partition := range f.partitions {
+		if in.GetPartitionName() == "" || partition.Name == in.GetPartitionName() {
+			reply.PartitionInfo = append(reply.PartitionInfo, proto.Clone(partition).(*craneProtos.PartitionInfo))
+		}
+	}
+	return reply, nil
+}
+
+func (f *FakeCraneCtld) ModifyTask(ctx context.Context, in *craneProtos.ModifyTaskRequest, opts ...grpc.CallOption) (*craneProtos.ModifyTaskReply, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.errs["ModifyTask"]; err != nil {
+		return nil, err
+	}
+	task, ok := f.tasks[in.GetTaskId()]
+	if !ok {
+		return &craneProtos.ModifyTaskReply{Ok: false, Reason: fmt.Sprintf("Task #%d was not found in running or pending queue.", in.GetTaskId())}, nil
+	}
+	if !isActive(task.Status) {
+		return &craneProtos.ModifyTaskReply{Ok: false, Reason: fmt.Sprintf("Task #%d is not running or pending.", in.GetTaskId())}, nil
+	}
+	if value, ok := in.GetValue().(*craneProtos.ModifyTaskRequest_TimeLimitSeconds); ok {
+		task.TimeLimit = &durationpb.Duration{Seconds: value.TimeLimitSeconds}
+	}
+	return &craneProtos.ModifyTaskReply{Ok: true}, nil
+}
+
+func (f *FakeCraneCtld) AddAccount(ctx context.Context, in *craneProtos.AddAccountRequest, opts ...grpc.CallOption) (*craneProtos.AddAccountReply, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.errs["AddAccount"]; err != nil {
+		return nil, err
+	}
+	account := in.GetAccount()
+	if account.GetName() == "" {
+		return &craneProtos.AddAccountReply{Ok: false, Reason: "Account name empty."}, nil
+	}
+	if _, ok := f.accounts[account.GetName()]; ok {
+		return &craneProtos.AddAccountReply{Ok: false, Reason: fmt.Sprintf("The account %s already exists in the database.", account.GetName())}, nil
+	}
+	account = proto.Clone(account).(*craneProtos.AccountInfo)
+	account.Users = nil
+	account.Blocked = false
+	f.accounts[account.Name] = account
+	return &craneProtos.AddAccountReply{Ok: true}, nil
+}
+
+func (f *FakeCraneCtld) AddUser(ctx context.Context, in *craneProtos.AddUserRequest, opts ...grpc.CallOption) (*craneProtos.AddUserReply, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.errs["AddUser"]; err != nil {
+		return nil, err
+	}
+	u := in.GetUser()
+	account, ok := f.accounts[u.GetAccount()]
+	if !ok {
+		return &craneProtos.AddUserReply{Ok: false, Reason: fmt.Sprintf("The account %s doesn't exist in the database.", u.GetAccount())}, nil
+	}
+	if f.findUser(u.GetName(), u.GetAccount()) != -1 {
+		return &craneProtos.AddUserReply{Ok: false, Reason: fmt.Sprintf("The user %s already exists in account %s.", u.GetName(), u.GetAccount())}, nil
+	}
+	f.users = append(f.users, proto.Clone(u).(*craneProtos.UserInfo))
+	account.Users = append(account.Users, u.GetName())
+	return &craneProtos.AddUserReply{Ok: true}, nil
+}
+
+func (f *FakeCraneCtld) QueryEntityInfo(ctx context.Context, in *craneProtos.QueryEntityInfoRequest, opts ...grpc.CallOption) (*craneProtos.QueryEntityInfoReply, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.errs["QueryEntityInfo"]; err != nil {
+		return nil, err
+	}
+	reply := &craneProtos.QueryEntityInfoReply{Ok: true}
+	switch in.GetEntityType() {
+	case craneProtos.EntityType_Account:
+		if in.GetName() != "" {
+			account, ok := f.accounts[in.GetName()]
+			if !ok {
+				return &craneProtos.QueryEntityInfoReply{Ok: false, Reason: fmt.Sprintf("Can't find account %s!", in.GetName())}, nil
+			}
+			reply.AccountList = append(reply.AccountList, proto.Clone(account).(*craneProtos.AccountInfo))
+			return reply, nil
+		}
+		names := make([]string, 0, len(f.accounts))
+		for name := range f.accounts {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			reply.AccountList = append(reply.AccountList, proto.Clone(f.accounts[name]).(*craneProtos.AccountInfo))
+		}
+	case craneProtos.EntityType_User:
+		if in.GetAccount() != "" {
+			if _, ok := f.accounts[in.GetAccount()]; !ok {
+				return &craneProtos.QueryEntityInfoReply{Ok: false, Reason: fmt.Sprintf("Can't find account %s!", in.GetAccount())}, nil
+			}
+		}
+		for _, u := range f.users {
+			if (in.GetName() == "" || u.Name == in.GetName()) && (in.GetAccount() == "" || u.Account == in.GetAccount()) {
+				reply.UserList = append(reply.UserList, f.userView(u))
+			}
+		}
+		if in.GetName() != "" && len(reply.UserList) == 0 {
+			return &craneProtos.QueryEntityInfoReply{Ok: false, Reason: fmt.Sprintf("Can't find user %s!", in.GetName())}, nil
+		}
+	case craneProtos.EntityType_Qos:
+		for _, qos := range f.qos {
+			if in.GetName() == "" || qos.Name == in.GetName() {
+				reply.QosList = append(reply.QosList, proto.Clone(qos).(*craneProtos.QosInfo))
+			}
+		}
+	}
+	return reply, nil
+}
+
+func (f *FakeCraneCtld) DeleteEntity(ctx context.Context, in *craneProtos.DeleteEntityRequest, opts ...grpc.CallOption) (*craneProtos.DeleteEntityReply, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.errs["DeleteEntity"]; err != nil {
+		return nil, err
+	}
+	switch in.GetEntityType() {
+	case craneProtos.EntityType_Account:
+		account, ok := f.accounts[in.GetName()]
+		if !ok {
+			return &craneProtos.DeleteEntityReply{Ok: false, Reason: fmt.Sprintf("Account %s doesn't exist in the database.", in.GetName())}, nil
+		}
+		if len(account.Users) != 0 {
+			return &craneProtos.DeleteEntityReply{Ok: false, Reason: fmt.Sprintf("Account %s still has users.", in.GetName())}, nil
+		}
+		delete(f.accounts, in.GetName())
+	case craneProtos.EntityType_User:
+		index := f.findUser(in.GetName(), in.GetAccount())
+		if index == -1 {
+			return &craneProtos.DeleteEntityReply{Ok: false, Reason: fmt.Sprintf("User %s doesn't exist in account %s.", in.GetName(), in.GetAccount())}, nil
+		}
+		f.users = append(f.users[:index], f.users[index+1:]...)
+		account := f.accounts[in.GetAccount()]
+		account.Users = removeString(account.Users, in.GetName())
+	default:
+		return &craneProtos.DeleteEntityReply{Ok: false, Reason: "Unsupported entity type."}, nil
+	}
+	return &craneProtos.DeleteEntityReply{Ok: true}, nil
+}
+
+func (f *FakeCraneCtld) BlockAccountOrUser(ctx context.Context, in *craneProtos.BlockAccountOrUserRequest, opts ...grpc.CallOption) (*craneProtos.BlockAccountOrUserReply, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.errs["BlockAccountOrUser"]; err != nil {
+		return nil, err
+	}
+	switch in.GetEntityType() {
+	case craneProtos.EntityType_Account:
+		account, ok := f.accounts[in.GetName()]
+		if !ok {
+			return &craneProtos.BlockAccountOrUserReply{Ok: false, Reason: fmt.Sprintf("Unknown account '%s'.", in.GetName())}, nil
+		}
+		// 和CraneCtld一样，重复封锁或解封时返回失败
+		if account.Blocked == in.GetBlock() {
+			return &craneProtos.BlockAccountOrUserReply{Ok: false, Reason: fmt.Sprintf("Account '%s' is already %s.", in.GetName(), blockedState(in.GetBlock()))}, nil
+		}
+		account.Blocked = in.GetBlock()
+	case craneProtos.EntityType_User:
+		index := f.findUser(in.GetName(), in.GetAccount())
+		if index == -1 {
+			return &craneProtos.BlockAccountOrUserReply{Ok: false, Reason: fmt.Sprintf("Unknown user '%s' in account '%s'.", in.GetName(), in.GetAccount())}, nil
+		}
+		if f.users[index].Blocked == in.GetBlock() {
+			return &craneProtos.BlockAccountOrUserReply{Ok: false, Reason: fmt.Sprintf("User '%s' is already %s in account '%s'.", in.GetName(), blockedState(in.GetBlock()), in.GetAccount())}, nil
+		}
+		f.users[index].Blocked = in.GetBlock()
+	default:
+		return &craneProtos.BlockAccountOrUserReply{Ok: false, Reason: "Unsupported entity type."}, nil
+	}
+	return &craneProtos.BlockAccountOrUserReply{Ok: true}, nil
+}
+
+func (f *FakeCraneCtld) QueryClusterInfo(ctx context.Context, in *craneProtos.QueryClusterInfoRequest, opts ...grpc.CallOption) (*craneProtos.QueryClusterInfoReply, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.errs["QueryClusterInfo"]; err != nil {
+		return nil, err
+	}
+	reply := &craneProtos.QueryClusterInfoReply{Ok: true}
+	for _, partition := range f.partitions {
+		if len(in.GetFilterPartitions()) != 0 && !containsString(in.GetFilterPartitions(), partition.Name) {
+			continue
+		}
+		// 和cinfo一样，按节点状态分组统计分区中的节点
+		trimmed := &craneProtos.TrimmedPartitionInfo{Name: partition.Name, State: partition.State}
+		for _, state := range []craneProtos.CranedState{craneProtos.CranedState_CRANE_IDLE, craneProtos.CranedState_CRANE_MIX, craneProtos.CranedState_CRANE_ALLOC, craneProtos.CranedState_CRANE_DOWN} {
+			if len(in.GetFilterCranedStates()) != 0 && !containsCranedState(in.GetFilterCranedStates(), state) {
+				continue
+			}
+			var hostnames []string
+			for _, craned := range f.craneds {
+				if craned.State == state && containsString(craned.PartitionNames, partition.Name) {
+					hostnames = append(hostnames, craned.Hostname)
+				}
+			}
+			if len(hostnames) == 0 {
+				continue
+			}
+			trimmed.CranedLists = append(trimmed.CranedLists, &craneProtos.TrimmedCranedInfo{
+				State:           state,
+				Count:           uint32(len(hostnames)),
+				CranedListRegex: strings.Join(hostnames, ","),
+			})
+		}
+		reply.Partitions = append(reply.Partitions, trimmed)
+	}
+	return reply, nil
+}
+
+func (f *FakeCraneCtld) QueryTasksInfo(ctx context.Context, in *craneProtos.QueryTasksInfoRequest, opts ...grpc.CallOption) (*craneProtos.QueryTasksInfoReply, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.lastTasksQuery = proto.Clone(in).(*craneProtos.QueryTasksInfoRequest)
+	if err := f.errs["QueryTasksInfo"]; err != nil {
+		return nil, err
+	}
+	taskIds := make([]uint32, 0, len(f.tasks))
+	for taskId := range f.tasks {
+		taskIds = append(taskIds, taskId)
+	}
+	sort.Slice(taskIds, func(i, j int) bool { return taskIds[i] < taskIds[j] })
+
+	reply := &craneProtos.QueryTasksInfoReply{Ok: true}
+	for _, taskId := range taskIds {
+		task := f.tasks[taskId]
+		if !in.GetOptionIncludeCompletedTasks() && !isActive(task.Status) {
+			continue
+		}
+		if len(in.GetFilterTaskIds()) != 0 && !containsUint32(in.GetFilterTaskIds(), task.TaskId) {
+			continue
+		}
+		if len(in.GetFilterTaskStates()) != 0 && !containsStatus(in.GetFilterTaskStates(), task.Status) {
+			continue
+		}
+		if len(in.GetFilterUsers()) != 0 && !containsString(in.GetFilterUsers(), task.Username) {
+			continue
+		}
+		if len(in.GetFilterAccounts()) != 0 && !containsString(in.GetFilterAccounts(), task.Account) {
+			continue
+		}
+		if len(in.GetFilterPartitions()) != 0 && !containsString(in.GetFilterPartitions(), task.Partition) {
+			continue
+		}
+		if len(in.GetFilterQos()) != 0 && !containsString(in.GetFilterQos(), task.Qos) {
+			continue
+		}
+		if len(in.GetFilterTaskNames()) != 0 && !containsString(in.GetFilterTaskNames(), task.Name) {
+			continue
+		}
+		if !inInterval(in.GetFilterStartTimeInterval(), task.StartTime) ||
+			!inInterval(in.GetFilterEndTimeInterval(), task.EndTime) ||
+			!inInterval(in.GetFilterSubmitTimeInterval(), task.SubmitTime) {
+			continue
+		}
+		if in.GetNumLimit() != 0 && uint32(len(reply.TaskInfoList)) >= in.GetNumLimit() {
+			break
+		}
+		reply.TaskInfoList = append(reply.TaskInfoList, proto.Clone(task).(*craneProtos.TaskInfo))
+	}
+	return reply, nil
+}
+
+func (f *FakeCraneCtld) hasPartition(name string) bool {
+	for _, partition := range f.partitions {
+		if partition.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func isActive(status craneProtos.TaskStatus) bool {
+	return status == craneProtos.TaskStatus_Pending || status == craneProtos.TaskStatus_Running
+}
+
+// 时间区间为空时不过滤，否则时间需要落在[LowerBound, UpperBound]内
+func inInterval(interval *craneProtos.TimeInterval, t *timestamppb.Timestamp) bool {
+	if interval == nil {
+		return true
+	}
+	if t == nil {
+		return false
+	}
+	value := t.AsTime()
+	if interval.LowerBound != nil && value.Before(interval.LowerBound.AsTime()) {
+		return false
+	}
+	if interval.UpperBound != nil && value.After(interval.UpperBound.AsTime()) {
+		return false
+	}
+	return true
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if strings.TrimSpace(item) == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsUint32(list []uint32, value uint32) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsStatus(list []craneProtos.TaskStatus, value craneProtos.TaskStatus) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsCranedState(list []craneProtos.CranedState, value craneProtos.CranedState) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+func removeString(list []string, value string) []string {
+	result := []string{}
+	for _, item := range list {
+		if item != value {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+func blockedState(block bool) string {
+	if block {
+		return "blocked"
+	}
+	return "unblocked"
+}
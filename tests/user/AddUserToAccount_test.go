@@ -1,3 +1,6 @@
+//go:build integration
+
+// 集成测试，需要在localhost:8972运行适配器并连接Crane集群，使用go test -tags integration运行
 package main
 
 import (
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	craneProtos "scow-crane-adapter/gen/crane"
+	protos "scow-crane-adapter/gen/go"
+	"scow-crane-adapter/tests/fakectld"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// 在fake CraneCtld中直接创建一个没有用户的账户
+func addTestAccount(t *testing.T, fake *fakectld.FakeCraneCtld, accountName string) {
+	t.Helper()
+	response, err := fake.AddAccount(context.Background(), &craneProtos.AddAccountRequest{Account: &craneProtos.AccountInfo{Name: accountName}})
+	if err != nil || !response.GetOk() {
+		t.Fatalf("AddAccount failed: %v %s", err, response.GetReason())
+	}
+}
+
+func TestAddUserToAccount(t *testing.T) {
+	fake, conn := newTestServer(t)
+	client := protos.NewUserServiceClient(conn)
+	addTestAccount(t, fake, "a_admin")
+	userId := currentUser(t)
+
+	_, err := client.AddUserToAccount(context.Background(), &protos.AddUserToAccountRequest{UserId: userId, AccountName: "a_admin"})
+	if err != nil {
+		t.Fatalf("AddUserToAccount failed: %v", err)
+	}
+
+	user := fake.User(userId, "a_admin")
+	if assert.NotNil(t, user) {
+		assert.Equal(t, craneProtos.UserInfo_None, user.AdminLevel)
+		assert.False(t, user.Blocked)
+		if assert.Len(t, user.AllowedPartitionQosList, 1) {
+			assert.Equal(t, "CPU", user.AllowedPartitionQosList[0].PartitionName)
+			assert.Equal(t, []string{"normal", "high"}, user.AllowedPartitionQosList[0].QosList)
+		}
+	}
+}
+
+func TestAddUserToAccountUserNotFound(t *testing.T) {
+	fake, conn := newTestServer(t)
+	client := protos.NewUserServiceClient(conn)
+	addTestAccount(t, fake, "a_admin")
+
+	_, err := client.AddUserToAccount(context.Background(), &protos.AddUserToAccountRequest{UserId: "scow_no_such_user", AccountName: "a_admin"})
+
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.Equal(t, "USER_NOT_FOUND", errorReason(err))
+}
+
+func TestAddUserToAccountAccountNotFound(t *testing.T) {
+	_, conn := newTestServer(t)
+	client := protos.NewUserServiceClient(conn)
+
+	_, err := client.AddUserToAccount(context.Background(), &protos.AddUserToAccountRequest{UserId: currentUser(t), AccountName: "not_exists"})
+
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.Equal(t, "ACCOUNT_NOT_FOUND", errorReason(err))
+}
+
+func TestRemoveUserFromAccount(t *testing.T) {
+	fake, conn := newTestServer(t)
+	client := protos.NewUserServiceClient(conn)
+	addTestAccount(t, fake, "a_admin")
+	userId := currentUser(t)
+	if _, err := client.AddUserToAccount(context.Background(), &protos.AddUserToAccountRequest{UserId: userId, AccountName: "a_admin"}); err != nil {
+		t.Fatalf("AddUserToAccount failed: %v", err)
+	}
+
+	_, err := client.RemoveUserFromAccount(context.Background(), &protos.RemoveUserFromAccountRequest{UserId: userId, AccountName: "a_admin"})
+	if err != nil {
+		t.Fatalf("RemoveUserFromAccount failed: %v", err)
+	}
+
+	assert.Nil(t, fake.User(userId, "a_admin"))
+}
+
+func TestBlockAndUnblockUserInAccount(t *testing.T) {
+	fake, conn := newTestServer(t)
+	client := protos.NewUserServiceClient(conn)
+	addTestAccount(t, fake, "a_admin")
+	userId := currentUser(t)
+	if _, err := client.AddUserToAccount(context.Background(), &protos.AddUserToAccountRequest{UserId: userId, AccountName: "a_admin"}); err != nil {
+		t.Fatalf("AddUserToAccount failed: %v", err)
+	}
+
+	_, err := client.BlockUserInAccount(context.Background(), &protos.BlockUserInAccountRequest{UserId: userId, AccountName: "a_admin"})
+	if err != nil {
+		t.Fatalf("BlockUserInAccount failed: %v", err)
+	}
+	res, err := client.QueryUserInAccountBlockStatus(context.Background(), &protos.QueryUserInAccountBlockStatusRequest{UserId: userId, AccountName: "a_admin"})
+	if err != nil {
+		t.Fatalf("QueryUserInAccountBlockStatus failed: %v", err)
+	}
+	assert.True(t, res.Blocked)
+
+	_, err = client.UnblockUserInAccount(context.Background(), &protos.UnblockUserInAccountRequest{UserId: userId, AccountName: "a_admin"})
+	if err != nil {
+		t.Fatalf("UnblockUserInAccount failed: %v", err)
+	}
+	res, err = client.QueryUserInAccountBlockStatus(context.Background(), &protos.QueryUserInAccountBlockStatusRequest{UserId: userId, AccountName: "a_admin"})
+	if err != nil {
+		t.Fatalf("QueryUserInAccountBlockStatus failed: %v", err)
+	}
+	assert.False(t, res.Blocked)
+}
+
+func TestBlockUserInAccountAssociationNotExists(t *testing.T) {
+	fake, conn := newTestServer(t)
+	client := protos.NewUserServiceClient(conn)
+	addTestAccount(t, fake, "a_admin")
+
+	_, err := client.BlockUserInAccount(context.Background(), &protos.BlockUserInAccountRequest{UserId: currentUser(t), AccountName: "a_admin"})
+
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestAddUserToAccountIsIdempotent(t *testing.T) {
+	fake, conn := newTestServer(t)
+	client := protos.NewUserServiceClient(conn)
+	addTestAccount(t, fake, "a_admin")
+	userId := currentUser(t)
+
+	for i := 0; i < 2; i++ {
+		_, err := client.AddUserToAccount(context.Background(), &protos.AddUserToAccountRequest{UserId: userId, AccountName: "a_admin"})
+		assert.NoError(t, err)
+	}
+	assert.NotNil(t, fake.User(userId, "a_admin"))
+}
+
+func TestBlockAndUnblockUserInAccountAreIdempotent(t *testing.T) {
+	fake, conn := newTestServer(t)
+	client := protos.NewUserServiceClient(conn)
+	addTestAccount(t, fake, "a_admin")
+	userId := currentUser(t)
+	if _, err := client.AddUserToAccount(context.Background(), &protos.AddUserToAccountRequest{UserId: userId, AccountName: "a_admin"}); err != nil {
+		t.Fatalf("AddUserToAccount failed: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		_, err := client.BlockUserInAccount(context.Background(), &protos.BlockUserInAccountRequest{UserId: userId, AccountName: "a_admin"})
+		assert.NoError(t, err)
+		assert.True(t, fake.User(userId, "a_admin").Blocked)
+	}
+	for i := 0; i < 2; i++ {
+		_, err := client.UnblockUserInAccount(context.Background(), &protos.UnblockUserInAccountRequest{UserId: userId, AccountName: "a_admin"})
+		assert.NoError(t, err)
+		assert.False(t, fake.User(userId, "a_admin").Blocked)
+	}
+}
+
+func TestBlockUserInAccountAccountNotFound(t *testing.T) {
+	_, conn := newTestServer(t)
+	client := protos.NewUserServiceClient(conn)
+
+	_, err := client.BlockUserInAccount(context.Background(), &protos.BlockUserInAccountRequest{UserId: currentUser(t), AccountName: "a_admin"})
+
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.Equal(t, "ACCOUNT_NOT_FOUND", errorReason(err))
+}
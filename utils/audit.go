@@ -0,0 +1,144 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// 审计日志中的一条记录，每条记录占一行
+type AuditEntry struct {
+	Time      time.Time       `json:"time"`
+	RequestId string          `json:"request_id,omitempty"`
+	Caller    AuditCaller     `json:"caller"`
+	Method    string          `json:"method"`
+	Account   string          `json:"account,omitempty"`
+	User      string          `json:"user,omitempty"`
+	JobId     uint32          `json:"job_id,omitempty"`
+	Params    json.RawMessage `json:"params,omitempty"`
+	Ok        bool            `json:"ok"`
+	Code      string          `json:"code"`
+	Reason    string          `json:"reason,omitempty"`
+	Message   string          `json:"message,omitempty"`
+}
+
+// 调用方信息，启用双向TLS时记录客户端证书的subject
+// 启用认证时记录认证方式(bearer或hmac)和匹配的token的标识，见TokenId
+type AuditCaller struct {
+	Peer    string `json:"peer,omitempty"`
+	Subject string `json:"subject,omitempty"`
+	Auth    string `json:"auth,omitempty"`
+	TokenId string `json:"token_id,omitempty"`
+}
+
+// 只追加的JSON lines审计日志，与普通日志分开存放和轮转
+type AuditLogger struct {
+	mu     sync.Mutex
+	writer io.WriteCloser
+}
+
+func NewAuditLogger(c AuditConfig) *AuditLogger {
+	return &AuditLogger{writer: &lumberjack.Logger{
+		Filename:   c.Path,
+		MaxSize:    c.MaxSize,
+		MaxBackups: c.MaxBackups,
+		MaxAge:     c.MaxAge,
+		LocalTime:  true,
+		Compress:   c.Compress,
+	}}
+}
+
+func (l *AuditLogger) Write(entry *AuditEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, err = l.writer.Write(append(line, '\n'))
+	return err
+}
+
+func (l *AuditLogger) Close() error {
+	return l.writer.Close()
+}
+
+// 为methods中列出的修改操作写入审计日志，包括认证失败等被拒绝的请求
+func AuditInterceptor(auditLogger *AuditLogger, methods []string, onError func(error)) grpc.UnaryServerInterceptor {
+	audited := map[string]bool{}
+	for _, method := range methods {
+		audited[method] = true
+	}
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if !audited[info.FullMethod] {
+			return handler(ctx, req)
+		}
+		ctx, identity := withAuthIdentity(ctx)
+		resp, err := handler(ctx, req)
+
+		entry := &AuditEntry{
+			Time:      time.Now(),
+			RequestId: RequestIdFromContext(ctx),
+			Caller:    auditCaller(ctx, identity),
+			Method:    info.FullMethod,
+			Ok:        err == nil,
+			Code:      status.Code(err).String(),
+		}
+		if err != nil {
+			entry.Reason = ErrorReason(err)
+			entry.Message = status.Convert(err).Message()
+		}
+		fillAuditTarget(entry, req)
+		// 提交作业时记录分配的作业id
+		fillAuditTarget(entry, resp)
+		if message, ok := req.(proto.Message); ok {
+			if params, err := protojson.Marshal(redactedClone(message)); err == nil {
+				entry.Params = params
+			}
+		}
+		if writeErr := auditLogger.Write(entry); writeErr != nil && onError != nil {
+			onError(writeErr)
+		}
+		return resp, err
+	}
+}
+
+func auditCaller(ctx context.Context, identity *authIdentity) AuditCaller {
+	var caller AuditCaller
+	caller.Auth, caller.TokenId = identity.get()
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return caller
+	}
+	caller.Peer = p.Addr.String()
+	if tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo); ok && len(tlsInfo.State.PeerCertificates) != 0 {
+		caller.Subject = tlsInfo.State.PeerCertificates[0].Subject.String()
+	}
+	return caller
+}
+
+// 从请求或响应中取出操作的账户、用户和作业
+func fillAuditTarget(entry *AuditEntry, message interface{}) {
+	if m, ok := message.(interface{ GetAccountName() string }); ok && m.GetAccountName() != "" {
+		entry.Account = m.GetAccountName()
+	}
+	if m, ok := message.(interface{ GetAccount() string }); ok && m.GetAccount() != "" {
+		entry.Account = m.GetAccount()
+	}
+	if m, ok := message.(interface{ GetUserId() string }); ok && m.GetUserId() != "" {
+		entry.User = m.GetUserId()
+	}
+	if m, ok := message.(interface{ GetJobId() uint32 }); ok && m.GetJobId() != 0 {
+		entry.JobId = m.GetJobId()
+	}
+}
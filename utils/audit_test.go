@@ -0,0 +1,129 @@
+package utils
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	protos "scow-crane-adapter/gen/go"
+
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+// 读取审计日志中的所有记录
+func readAuditEntries(t *testing.T, path string) []AuditEntry {
+	t.Helper()
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read audit log failed: %v", err)
+	}
+	var entries []AuditEntry
+	for _, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+		var entry AuditEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("invalid audit line %q: %v", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestAuditInterceptor(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	auditLogger := NewAuditLogger(AuditConfig{Enabled: true, Path: path})
+	const submitMethod = "/scow.scheduler_adapter.JobService/SubmitScriptAsJob"
+	const blockMethod = "/scow.scheduler_adapter.AccountService/BlockAccount"
+	interceptor := AuditInterceptor(auditLogger, []string{submitMethod, blockMethod}, func(err error) { t.Errorf("write audit log failed: %v", err) })
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(RequestIdMetadataKey, "req-1"))
+	logger, _ := test.NewNullLogger()
+	logging := LoggingInterceptor(logger)
+	call := func(method string, req interface{}, handler grpc.UnaryHandler) {
+		logging(ctx, req, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return interceptor(ctx, req, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+		})
+	}
+
+	call(submitMethod, &protos.SubmitScriptAsJobRequest{UserId: "demo", Script: "echo secret"}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return &protos.SubmitScriptAsJobResponse{JobId: 42}, nil
+	})
+	call(blockMethod, &protos.BlockAccountRequest{AccountName: "a_demo"}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, RichError(codes.Internal, "CRANE_INTERNAL_ERROR", "Account a_demo is already blocked")
+	})
+	// 查询操作不写入审计日志
+	call("/scow.scheduler_adapter.JobService/GetJobs", &protos.GetJobsRequest{}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return &protos.GetJobsResponse{}, nil
+	})
+	auditLogger.Close()
+
+	entries := readAuditEntries(t, path)
+	if !assert.Len(t, entries, 2) {
+		return
+	}
+	assert.Equal(t, submitMethod, entries[0].Method)
+	assert.Equal(t, "req-1", entries[0].RequestId)
+	assert.Equal(t, "demo", entries[0].User)
+	assert.Equal(t, uint32(42), entries[0].JobId)
+	assert.True(t, entries[0].Ok)
+	assert.NotContains(t, string(entries[0].Params), "secret")
+
+	assert.Equal(t, blockMethod, entries[1].Method)
+	assert.Equal(t, "a_demo", entries[1].Account)
+	assert.False(t, entries[1].Ok)
+	assert.Equal(t, "Internal", entries[1].Code)
+	assert.Equal(t, "CRANE_INTERNAL_ERROR", entries[1].Reason)
+	assert.Equal(t, "Account a_demo is already blocked", entries[1].Message)
+}
+
+func TestAuditInterceptorRecordsAuthIdentity(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	auditLogger := NewAuditLogger(AuditConfig{Enabled: true, Path: path})
+	audit := AuditInterceptor(auditLogger, []string{testMethod}, func(err error) { t.Errorf("write audit log failed: %v", err) })
+	auth, _ := newTestAuthInterceptor(t, "token-a\ntoken-b\n")
+	// 与main.go中的顺序相同，审计拦截器在认证拦截器外层
+	call := func(pairs ...string) {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(pairs...))
+		ctx = context.WithValue(ctx, rawRequestKey{}, &rawRequest{ok: true})
+		info := &grpc.UnaryServerInfo{FullMethod: testMethod}
+		audit(ctx, &protos.CreateAccountRequest{AccountName: "a_demo"}, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return auth(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+				return &protos.CreateAccountResponse{}, nil
+			})
+		})
+	}
+
+	call(AuthorizationMetadataKey, "Bearer token-b")
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	call(TimestampMetadataKey, timestamp, SignatureMetadataKey, hex.EncodeToString(Sign([]byte("token-a"), testMethod, timestamp, nil)))
+	call(AuthorizationMetadataKey, "Bearer wrong-token")
+	auditLogger.Close()
+
+	content, _ := ioutil.ReadFile(path)
+	assert.NotContains(t, string(content), "token-a")
+	assert.NotContains(t, string(content), "token-b")
+	entries := readAuditEntries(t, path)
+	if !assert.Len(t, entries, 3) {
+		return
+	}
+	assert.True(t, entries[0].Ok)
+	assert.Equal(t, AuditCaller{Auth: "bearer", TokenId: TokenId([]byte("token-b"))}, entries[0].Caller)
+	assert.True(t, entries[1].Ok)
+	assert.Equal(t, AuditCaller{Auth: "hmac", TokenId: TokenId([]byte("token-a"))}, entries[1].Caller)
+	// 认证失败的请求没有调用方身份
+	assert.False(t, entries[2].Ok)
+	assert.Equal(t, AuditCaller{}, entries[2].Caller)
+}
+
+func TestTokenId(t *testing.T) {
+	// echo -n token-a | sha256sum
+	assert.Equal(t, "a70bf50e531ce1a8", TokenId([]byte("token-a")))
+}
@@ -0,0 +1,206 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+// 认证相关的metadata键
+const (
+	AuthorizationMetadataKey = "authorization"    // Bearer <token>
+	TimestampMetadataKey     = "x-scow-timestamp" // HMAC签名使用的unix时间戳（秒）
+	SignatureMetadataKey     = "x-scow-signature" // hex(HMAC-SHA256(token, method + "\n" + timestamp + "\n" + hex(SHA256(请求消息的原始字节))))
+)
+
+// HMAC签名中时间戳允许的最大偏差，防止签名被重放
+const signatureMaxSkew = 5 * time.Minute
+
+// 从文件中读取的有效token，文件更新后自动重新加载
+// 文件中每行一个token，轮换时可以同时保留新旧token，忽略空行和#开头的注释
+type tokenStore struct {
+	path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	tokens  [][]byte
+}
+
+// 读取token文件，文件中没有token时返回错误
+func readTokens(path string) ([][]byte, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read token file: %w", err)
+	}
+	var tokens [][]byte
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		tokens = append(tokens, []byte(line))
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("no token found in %s", path)
+	}
+	return tokens, nil
+}
+
+// 获取当前有效的token，文件有更新时重新读取，读取失败时继续使用之前的token
+func (s *tokenStore) current() [][]byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if info, err := os.Stat(s.path); err == nil && info.ModTime() != s.modTime {
+		if tokens, err := readTokens(s.path); err == nil {
+			s.tokens = tokens
+			s.modTime = info.ModTime()
+		}
+	}
+	return s.tokens
+}
+
+// 认证方式，记录在审计日志中
+const (
+	authSchemeBearer = "bearer"
+	authSchemeHmac   = "hmac"
+)
+
+// 认证通过的调用方身份，由认证拦截器填写，审计拦截器写入审计日志
+// 审计拦截器在认证拦截器外层，需要先在context中放入身份再由内层填写
+type authIdentityKey struct{}
+
+type authIdentity struct {
+	mu      sync.Mutex
+	scheme  string
+	tokenId string
+}
+
+// 获取context中的调用方身份，没有时放入一个新的
+func withAuthIdentity(ctx context.Context) (context.Context, *authIdentity) {
+	if identity, ok := ctx.Value(authIdentityKey{}).(*authIdentity); ok {
+		return ctx, identity
+	}
+	identity := &authIdentity{}
+	return context.WithValue(ctx, authIdentityKey{}, identity), identity
+}
+
+func (i *authIdentity) set(scheme string, token []byte) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.scheme, i.tokenId = scheme, TokenId(token)
+}
+
+func (i *authIdentity) get() (scheme string, tokenId string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.scheme, i.tokenId
+}
+
+// TokenId 返回token的标识，用于在审计日志中区分调用方而不泄露token
+// 标识为token的SHA256的前16个十六进制字符，可以用 echo -n <token> | sha256sum 计算
+func TokenId(token []byte) string {
+	digest := sha256.Sum256(token)
+	return hex.EncodeToString(digest[:8])
+}
+
+// 校验bearer token或HMAC签名，通过时返回认证方式和匹配的token
+func (s *tokenStore) authenticate(ctx context.Context, method string, now time.Time) (scheme string, token []byte, err error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	tokens := s.current()
+	if values := md.Get(AuthorizationMetadataKey); len(values) != 0 {
+		if !strings.HasPrefix(values[0], "Bearer ") {
+			return "", nil, fmt.Errorf("authorization must be a bearer token")
+		}
+		bearer := strings.TrimPrefix(values[0], "Bearer ")
+		for _, valid := range tokens {
+			if subtle.ConstantTimeCompare([]byte(bearer), valid) == 1 {
+				return authSchemeBearer, valid, nil
+			}
+		}
+		return "", nil, fmt.Errorf("invalid token")
+	}
+
+	timestamps, signatures := md.Get(TimestampMetadataKey), md.Get(SignatureMetadataKey)
+	if len(timestamps) == 0 || len(signatures) == 0 {
+		return "", nil, fmt.Errorf("missing credentials")
+	}
+	timestamp, err := strconv.ParseInt(timestamps[0], 10, 64)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid timestamp")
+	}
+	if skew := now.Sub(time.Unix(timestamp, 0)); skew > signatureMaxSkew || skew < -signatureMaxSkew {
+		return "", nil, fmt.Errorf("timestamp is out of the allowed window")
+	}
+	signature, err := hex.DecodeString(signatures[0])
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid signature")
+	}
+	body, ok := rawRequestFromContext(ctx)
+	if !ok {
+		return "", nil, fmt.Errorf("request bytes are unavailable for signature verification")
+	}
+	for _, valid := range tokens {
+		if hmac.Equal(signature, Sign(valid, method, timestamps[0], body)) {
+			return authSchemeHmac, valid, nil
+		}
+	}
+	return "", nil, fmt.Errorf("invalid signature")
+}
+
+// 计算请求的HMAC签名，调用方使用同样的方式签名
+// body为调用方发送的请求消息的protobuf编码(不含gRPC的5字节帧头)，截获的签名不能用于内容不同的请求
+func Sign(token []byte, method string, timestamp string, body []byte) []byte {
+	digest := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, token)
+	mac.Write([]byte(method + "\n" + timestamp + "\n" + hex.EncodeToString(digest[:])))
+	return mac.Sum(nil)
+}
+
+// 校验调用方提供的bearer token或HMAC签名，只检查services中列出的服务
+// 校验HMAC签名需要请求的原始字节，服务端需要同时添加RawRequestServerOptions
+func AuthInterceptor(tokenFilePath string, services []string) (grpc.UnaryServerInterceptor, error) {
+	tokens, err := readTokens(tokenFilePath)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(tokenFilePath)
+	if err != nil {
+		return nil, err
+	}
+	store := &tokenStore{path: tokenFilePath, modTime: info.ModTime(), tokens: tokens}
+	protected := map[string]bool{}
+	for _, service := range services {
+		protected[service] = true
+	}
+
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		// FullMethod的格式为/<service>/<method>
+		service := strings.SplitN(strings.TrimPrefix(info.FullMethod, "/"), "/", 2)[0]
+		if !protected[service] {
+			return handler(ctx, req)
+		}
+		scheme, token, err := store.authenticate(ctx, info.FullMethod, time.Now())
+		if err != nil {
+			return nil, RichError(codes.Unauthenticated, "UNAUTHENTICATED", err.Error())
+		}
+		ctx, identity := withAuthIdentity(ctx)
+		identity.set(scheme, token)
+		return handler(ctx, req)
+	}, nil
+}
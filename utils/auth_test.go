@@ -0,0 +1,196 @@
+package utils
+
+import (
+	"context"
+	"encoding/hex"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+
+	protos "scow-crane-adapter/gen/go"
+)
+
+const testMethod = "/scow.scheduler_adapter.AccountService/CreateAccount"
+
+func writeTokenFile(t *testing.T, path string, content string, modTime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("write token file failed: %v", err)
+	}
+	os.Chtimes(path, modTime, modTime)
+}
+
+// 使用给定的metadata调用拦截器，返回拦截器的错误
+func callWithMetadata(interceptor grpc.UnaryServerInterceptor, method string, pairs ...string) error {
+	return callWithRequest(interceptor, method, nil, pairs...)
+}
+
+// 使用给定的请求原始字节和metadata调用拦截器，模拟RawRequestServerOptions记录的原始字节
+func callWithRequest(interceptor grpc.UnaryServerInterceptor, method string, body []byte, pairs ...string) error {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(pairs...))
+	ctx = context.WithValue(ctx, rawRequestKey{}, &rawRequest{data: body, ok: true})
+	_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, nil
+	})
+	return err
+}
+
+func newTestAuthInterceptor(t *testing.T, content string) (grpc.UnaryServerInterceptor, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "tokens")
+	writeTokenFile(t, path, content, time.Now().Add(-time.Minute))
+	interceptor, err := AuthInterceptor(path, []string{"scow.scheduler_adapter.AccountService"})
+	if err != nil {
+		t.Fatalf("AuthInterceptor failed: %v", err)
+	}
+	return interceptor, path
+}
+
+func TestAuthInterceptorBearerToken(t *testing.T) {
+	interceptor, _ := newTestAuthInterceptor(t, "# tokens for scow\nold-token\nnew-token\n")
+
+	assert.NoError(t, callWithMetadata(interceptor, testMethod, AuthorizationMetadataKey, "Bearer old-token"))
+	assert.NoError(t, callWithMetadata(interceptor, testMethod, AuthorizationMetadataKey, "Bearer new-token"))
+
+	err := callWithMetadata(interceptor, testMethod, AuthorizationMetadataKey, "Bearer wrong-token")
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	assert.Equal(t, "UNAUTHENTICATED", ErrorReason(err))
+	assert.Equal(t, codes.Unauthenticated, status.Code(callWithMetadata(interceptor, testMethod)))
+}
+
+func TestAuthInterceptorUnprotectedService(t *testing.T) {
+	interceptor, _ := newTestAuthInterceptor(t, "token\n")
+
+	assert.NoError(t, callWithMetadata(interceptor, "/scow.scheduler_adapter.VersionService/GetVersion"))
+}
+
+func TestAuthInterceptorSignature(t *testing.T) {
+	interceptor, _ := newTestAuthInterceptor(t, "token\n")
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	signature := hex.EncodeToString(Sign([]byte("token"), testMethod, timestamp, nil))
+
+	assert.NoError(t, callWithMetadata(interceptor, testMethod, TimestampMetadataKey, timestamp, SignatureMetadataKey, signature))
+
+	// 签名绑定了方法名，不能用于其他方法
+	err := callWithMetadata(interceptor, "/scow.scheduler_adapter.AccountService/BlockAccount", TimestampMetadataKey, timestamp, SignatureMetadataKey, signature)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	// 过期的签名被拒绝
+	expired := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+	expiredSignature := hex.EncodeToString(Sign([]byte("token"), testMethod, expired, nil))
+	err = callWithMetadata(interceptor, testMethod, TimestampMetadataKey, expired, SignatureMetadataKey, expiredSignature)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestAuthInterceptorSignatureCoversRequestBody(t *testing.T) {
+	interceptor, _ := newTestAuthInterceptor(t, "token\n")
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	body, _ := proto.Marshal(&protos.CreateAccountRequest{AccountName: "a_admin", OwnerUserId: "demo"})
+	signature := hex.EncodeToString(Sign([]byte("token"), testMethod, timestamp, body))
+
+	assert.NoError(t, callWithRequest(interceptor, testMethod, body, TimestampMetadataKey, timestamp, SignatureMetadataKey, signature))
+
+	// 签名不能用于内容被修改的请求
+	tampered, _ := proto.Marshal(&protos.CreateAccountRequest{AccountName: "a_admin", OwnerUserId: "attacker"})
+	err := callWithRequest(interceptor, testMethod, tampered, TimestampMetadataKey, timestamp, SignatureMetadataKey, signature)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	assert.Equal(t, "UNAUTHENTICATED", ErrorReason(err))
+}
+
+func TestAuthInterceptorReloadsTokenFile(t *testing.T) {
+	interceptor, path := newTestAuthInterceptor(t, "old-token\n")
+
+	writeTokenFile(t, path, "new-token\n", time.Now())
+
+	assert.NoError(t, callWithMetadata(interceptor, testMethod, AuthorizationMetadataKey, "Bearer new-token"))
+	assert.Equal(t, codes.Unauthenticated, status.Code(callWithMetadata(interceptor, testMethod, AuthorizationMetadataKey, "Bearer old-token")))
+
+	// 文件内容无效时继续使用之前的token
+	writeTokenFile(t, path, "# empty\n", time.Now().Add(time.Minute))
+	assert.NoError(t, callWithMetadata(interceptor, testMethod, AuthorizationMetadataKey, "Bearer new-token"))
+}
+
+func TestAuthInterceptorEmptyTokenFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens")
+	writeTokenFile(t, path, "\n# no tokens\n", time.Now())
+
+	_, err := AuthInterceptor(path, nil)
+
+	assert.Error(t, err)
+}
+
+// 客户端发送固定的请求字节，模拟编码方式与Go不同的protobuf实现
+type fixedBytesCodec struct {
+	data []byte
+}
+
+func (c fixedBytesCodec) Marshal(v interface{}) ([]byte, error) { return c.data, nil }
+
+func (c fixedBytesCodec) Unmarshal(data []byte, v interface{}) error {
+	return proto.Unmarshal(data, v.(proto.Message))
+}
+
+func (c fixedBytesCodec) Name() string { return "proto" }
+
+type testAccountServer struct {
+	protos.UnimplementedAccountServiceServer
+	received *protos.CreateAccountRequest
+}
+
+func (s *testAccountServer) CreateAccount(ctx context.Context, in *protos.CreateAccountRequest) (*protos.CreateAccountResponse, error) {
+	s.received = in
+	return &protos.CreateAccountResponse{}, nil
+}
+
+func TestAuthInterceptorSignsReceivedBytes(t *testing.T) {
+	interceptor, _ := newTestAuthInterceptor(t, "token\n")
+	lis := bufconn.Listen(1024 * 1024)
+	s := grpc.NewServer(append(RawRequestServerOptions(), grpc.ChainUnaryInterceptor(interceptor))...)
+	server := &testAccountServer{}
+	protos.RegisterAccountServiceServer(s, server)
+	go s.Serve(lis)
+	defer s.Stop()
+	conn, err := grpc.Dial("bufnet", grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+		return lis.DialContext(ctx)
+	}), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer conn.Close()
+
+	// 字段按编号倒序编码，服务端重新编码得到的字节不同
+	fields := (&protos.CreateAccountRequest{}).ProtoReflect().Descriptor().Fields()
+	var body []byte
+	body = protowire.AppendTag(body, fields.ByName("owner_user_id").Number(), protowire.BytesType)
+	body = protowire.AppendString(body, "demo")
+	body = protowire.AppendTag(body, fields.ByName("account_name").Number(), protowire.BytesType)
+	body = protowire.AppendString(body, "a_admin")
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	signature := hex.EncodeToString(Sign([]byte("token"), testMethod, timestamp, body))
+	ctx := metadata.AppendToOutgoingContext(context.Background(), TimestampMetadataKey, timestamp, SignatureMetadataKey, signature)
+
+	client := protos.NewAccountServiceClient(conn)
+	_, err = client.CreateAccount(ctx, &protos.CreateAccountRequest{}, grpc.ForceCodec(fixedBytesCodec{body}))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "a_admin", server.received.GetAccountName())
+		assert.Equal(t, "demo", server.received.GetOwnerUserId())
+	}
+
+	// 修改后的请求字节与签名不匹配
+	tampered := append(append([]byte(nil), body...), protowire.AppendString(protowire.AppendTag(nil, fields.ByName("owner_user_id").Number(), protowire.BytesType), "attacker")...)
+	_, err = client.CreateAccount(ctx, &protos.CreateAccountRequest{}, grpc.ForceCodec(fixedBytesCodec{tampered}))
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
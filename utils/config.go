@@ -0,0 +1,378 @@
+package utils
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+)
+
+// Crane的配置，从Crane自身的配置文件中读取
+type Config struct {
+	ClusterName         string `yaml:"ClusterName"`
+	ControlMachine      string `yaml:"ControlMachine"`
+	CraneCtldListenPort string `yaml:"CraneCtldListenPort"`
+
+	UseTls             bool        `yaml:"UseTls"`
+	ServerCertFilePath string      `yaml:"ServerCertFilePath"`
+	ServerKeyFilePath  string      `yaml:"ServerKeyFilePath"`
+	CaCertFilePath     string      `yaml:"CaCertFilePath"`
+	DomainSuffix       string      `yaml:"DomainSuffix"`
+	Partitions         []Partition `yaml:"Partitions"`
+}
+
+type Partition struct {
+	Name  string `yaml:"name"`
+	Nodes string `yaml:"nodes"`
+}
+
+var DefaultConfigPath = "/etc/crane/config.yaml"
+
+// 适配器自身的配置，与Crane的配置文件分开
+type AdapterConfig struct {
+	ListenAddress   string `yaml:"ListenAddress"`   // 适配器gRPC服务监听地址
+	CraneConfigPath string `yaml:"CraneConfigPath"` // Crane配置文件路径
+
+	// 以下配置为空时使用Crane配置文件中的值
+	CraneCtldAddress string   `yaml:"CraneCtldAddress"` // CraneCtld地址，默认为ControlMachine:CraneCtldListenPort
+	ClusterName      string   `yaml:"ClusterName"`
+	Partitions       []string `yaml:"Partitions"`
+
+	// 请求未设置超时时使用的默认超时，超时后取消对CraneCtld的调用和cbatch，为0时不限制
+	RequestTimeout time.Duration `yaml:"RequestTimeout"`
+
+	Tls      TlsConfig     `yaml:"Tls"`
+	Auth     AuthConfig    `yaml:"Auth"`
+	Log      LogConfig     `yaml:"Log"`
+	Audit    AuditConfig   `yaml:"Audit"`
+	Metrics  MetricsConfig `yaml:"Metrics"`
+	Tracing  TracingConfig `yaml:"Tracing"`
+	Jobs     JobsConfig    `yaml:"Jobs"`
+	Features FeatureConfig `yaml:"Features"`
+}
+
+// 适配器gRPC服务的TLS配置，证书文件更新后自动生效
+type TlsConfig struct {
+	Enabled          bool   `yaml:"Enabled"`
+	CertFilePath     string `yaml:"CertFilePath"`     // 服务端证书
+	KeyFilePath      string `yaml:"KeyFilePath"`      // 服务端私钥
+	ClientCaFilePath string `yaml:"ClientCaFilePath"` // 设置后要求SCOW提供由该CA签发的客户端证书
+}
+
+// 调用方认证配置，启用后Job、Account、User和Config服务要求提供有效的token或HMAC签名
+type AuthConfig struct {
+	Enabled       bool   `yaml:"Enabled"`
+	TokenFilePath string `yaml:"TokenFilePath"` // 每行一个有效token，文件更新后自动生效
+}
+
+type LogConfig struct {
+	Path       string       `yaml:"Path"`       // 日志文件路径，为空时不写入文件
+	Level      string       `yaml:"Level"`      // 日志级别，可以通过SIGHUP重新加载
+	Format     string       `yaml:"Format"`     // 日志格式，json或text
+	Stdout     bool         `yaml:"Stdout"`     // 是否同时输出到标准输出
+	MaxSize    int          `yaml:"MaxSize"`    // 日志文件的最大大小（以MB为单位）
+	MaxBackups int          `yaml:"MaxBackups"` // 保留的旧日志文件数量
+	MaxAge     int          `yaml:"MaxAge"`     // 保留的旧日志文件的最大天数
+	Compress   bool         `yaml:"Compress"`   // 是否压缩旧日志文件
+	Syslog     SyslogConfig `yaml:"Syslog"`
+}
+
+// 将日志同时发送到syslog，Address为空时使用本机的/dev/log，由systemd管理时日志会进入journald
+type SyslogConfig struct {
+	Enabled bool   `yaml:"Enabled"`
+	Network string `yaml:"Network"` // 远程syslog的协议，udp或tcp
+	Address string `yaml:"Address"` // 远程syslog的地址
+	Tag     string `yaml:"Tag"`
+}
+
+// 审计日志配置，记录账户、用户和作业的修改操作，与普通日志分开轮转
+type AuditConfig struct {
+	Enabled    bool   `yaml:"Enabled"`
+	Path       string `yaml:"Path"`       // 审计日志文件路径
+	MaxSize    int    `yaml:"MaxSize"`    // 审计日志文件的最大大小（以MB为单位）
+	MaxBackups int    `yaml:"MaxBackups"` // 保留的旧审计日志文件数量，为0时全部保留
+	MaxAge     int    `yaml:"MaxAge"`     // 保留的旧审计日志文件的最大天数，为0时不按时间删除
+	Compress   bool   `yaml:"Compress"`   // 是否压缩旧审计日志文件
+}
+
+// Prometheus指标的HTTP服务配置
+type MetricsConfig struct {
+	Enabled       bool   `yaml:"Enabled"`
+	ListenAddress string `yaml:"ListenAddress"` // 指标HTTP服务监听地址，指标路径为/metrics
+}
+
+// OpenTelemetry tracing配置
+type TracingConfig struct {
+	Enabled      bool   `yaml:"Enabled"`
+	Exporter     string `yaml:"Exporter"`     // span的导出方式，otlp或file
+	OtlpEndpoint string `yaml:"OtlpEndpoint"` // OTLP gRPC collector地址
+	FilePath     string `yaml:"FilePath"`     // file导出方式下span写入的文件，每行一个span
+}
+
+// 作业查询配置
+type JobsConfig struct {
+	// GetJobs每个请求最多从CraneCtld查询的作业数，限制单个请求占用的内存和CraneCtld回复的大小
+	// 匹配的作业超过该值时，不分页、排序或按作业名筛选的请求返回TOO_MANY_JOBS，
+	// 分页请求只在前MaxJobsPerRequest个作业中分页，TotalCount最大为MaxJobsPerRequest
+	MaxJobsPerRequest uint32 `yaml:"MaxJobsPerRequest"`
+}
+
+type FeatureConfig struct {
+	SubmitMode string `yaml:"SubmitMode"` // 提交作业的方式，grpc或cbatch
+}
+
+var DefaultAdapterConfigPath = "/etc/scow/crane-adapter.yaml"
+
+const (
+	LogFormatJson = "json"
+	LogFormatText = "text"
+)
+
+const (
+	TracingExporterOtlp = "otlp" // 通过OTLP gRPC导出到collector
+	TracingExporterFile = "file" // 以JSON写入文件，用于离线分析
+)
+
+const (
+	SubmitModeGrpc   = "grpc"   // 直接调用CraneCtld的SubmitBatchTask提交作业
+	SubmitModeCbatch = "cbatch" // 以用户身份执行cbatch提交作业
+)
+
+// 配置错误，包含配置文件中发现的所有问题
+type ConfigError struct {
+	Problems []string
+}
+
+func (e *ConfigError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// 适配器配置的默认值，与之前写死在代码中的值保持一致
+func DefaultAdapterConfig() *AdapterConfig {
+	return &AdapterConfig{
+		ListenAddress:   ":8972",
+		CraneConfigPath: DefaultConfigPath,
+		RequestTimeout:  60 * time.Second,
+		Log: LogConfig{
+			Path:       "server.log",
+			Level:      "info",
+			Format:     LogFormatJson,
+			Stdout:     true,
+			MaxSize:    10,
+			MaxBackups: 3,
+			MaxAge:     28,
+			Compress:   true,
+			Syslog: SyslogConfig{
+				Tag: "scow-crane-adapter",
+			},
+		},
+		Audit: AuditConfig{
+			Path:     "audit.log",
+			MaxSize:  100,
+			Compress: true,
+		},
+		Metrics: MetricsConfig{
+			ListenAddress: ":8973",
+		},
+		Tracing: TracingConfig{
+			Exporter:     TracingExporterOtlp,
+			OtlpEndpoint: "localhost:4317",
+		},
+		Jobs: JobsConfig{
+			MaxJobsPerRequest: 100000,
+		},
+		Features: FeatureConfig{
+			SubmitMode: SubmitModeGrpc,
+		},
+	}
+}
+
+// 解析crane配置文件
+func ParseConfig(configFilePath string) (*Config, error) {
+	confFile, err := ioutil.ReadFile(configFilePath)
+	if err != nil {
+		return nil, fmt.Errorf("read crane config: %w", err)
+	}
+	config := &Config{}
+	err = yaml.Unmarshal(confFile, config)
+	if err != nil {
+		return nil, fmt.Errorf("parse crane config %s: %w", configFilePath, err)
+	}
+	return config, nil
+}
+
+// 解析适配器配置文件，未设置的配置项使用默认值
+// required为false时配置文件不存在则直接使用默认配置
+func ParseAdapterConfig(configFilePath string, required bool) (*AdapterConfig, error) {
+	adapterConfig := DefaultAdapterConfig()
+	confFile, err := ioutil.ReadFile(configFilePath)
+	if os.IsNotExist(err) && !required {
+		return adapterConfig, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read adapter config: %w", err)
+	}
+	err = yaml.UnmarshalStrict(confFile, adapterConfig)
+	if err != nil {
+		return nil, fmt.Errorf("parse adapter config %s: %w", configFilePath, err)
+	}
+	return adapterConfig, nil
+}
+
+// 校验crane配置，返回发现的所有问题
+func (c *Config) Validate() []string {
+	var problems []string
+	if c.ControlMachine == "" {
+		problems = append(problems, "ControlMachine is required in crane config")
+	}
+	if c.CraneCtldListenPort == "" {
+		problems = append(problems, "CraneCtldListenPort is required in crane config")
+	}
+	if len(c.Partitions) == 0 {
+		problems = append(problems, "Partitions must not be empty")
+	}
+	for i, partition := range c.Partitions {
+		if partition.Name == "" {
+			problems = append(problems, fmt.Sprintf("Partitions[%d] has no name", i))
+		}
+	}
+	if c.UseTls {
+		for _, file := range []struct{ name, path string }{
+			{"ServerCertFilePath", c.ServerCertFilePath},
+			{"ServerKeyFilePath", c.ServerKeyFilePath},
+			{"CaCertFilePath", c.CaCertFilePath},
+		} {
+			if file.path == "" {
+				problems = append(problems, fmt.Sprintf("%s is required when UseTls is true", file.name))
+			} else if _, err := os.Stat(file.path); err != nil {
+				problems = append(problems, fmt.Sprintf("%s is not readable: %v", file.name, err))
+			}
+		}
+	}
+	return problems
+}
+
+// 校验适配器配置，返回发现的所有问题
+func (c *AdapterConfig) Validate() []string {
+	var problems []string
+	if c.ListenAddress == "" {
+		problems = append(problems, "ListenAddress must not be empty")
+	}
+	if c.RequestTimeout < 0 {
+		problems = append(problems, "RequestTimeout must not be negative")
+	}
+	if c.Tls.Enabled {
+		for _, file := range []struct{ name, path string }{
+			{"Tls.CertFilePath", c.Tls.CertFilePath},
+			{"Tls.KeyFilePath", c.Tls.KeyFilePath},
+		} {
+			if file.path == "" {
+				problems = append(problems, fmt.Sprintf("%s is required when Tls.Enabled is true", file.name))
+			} else if _, err := os.Stat(file.path); err != nil {
+				problems = append(problems, fmt.Sprintf("%s is not readable: %v", file.name, err))
+			}
+		}
+		if c.Tls.ClientCaFilePath != "" {
+			if _, err := os.Stat(c.Tls.ClientCaFilePath); err != nil {
+				problems = append(problems, fmt.Sprintf("Tls.ClientCaFilePath is not readable: %v", err))
+			}
+		}
+	}
+	if c.Audit.Enabled {
+		if c.Audit.Path == "" {
+			problems = append(problems, "Audit.Path must not be empty when Audit.Enabled is true")
+		} else if c.Audit.Path == c.Log.Path {
+			problems = append(problems, "Audit.Path must be different from Log.Path")
+		}
+	}
+	if c.Audit.MaxSize < 0 || c.Audit.MaxBackups < 0 || c.Audit.MaxAge < 0 {
+		problems = append(problems, "Audit.MaxSize, Audit.MaxBackups and Audit.MaxAge must not be negative")
+	}
+	if c.Metrics.Enabled && c.Metrics.ListenAddress == "" {
+		problems = append(problems, "Metrics.ListenAddress must not be empty when Metrics.Enabled is true")
+	}
+	if c.Tracing.Enabled {
+		switch c.Tracing.Exporter {
+		case TracingExporterOtlp:
+			if c.Tracing.OtlpEndpoint == "" {
+				problems = append(problems, "Tracing.OtlpEndpoint must not be empty when Tracing.Exporter is otlp")
+			}
+		case TracingExporterFile:
+			if c.Tracing.FilePath == "" {
+				problems = append(problems, "Tracing.FilePath must not be empty when Tracing.Exporter is file")
+			}
+		default:
+			problems = append(problems, fmt.Sprintf("Tracing.Exporter must be %q or %q, got %q", TracingExporterOtlp, TracingExporterFile, c.Tracing.Exporter))
+		}
+	}
+	if c.Auth.Enabled {
+		if c.Auth.TokenFilePath == "" {
+			problems = append(problems, "Auth.TokenFilePath is required when Auth.Enabled is true")
+		} else if _, err := readTokens(c.Auth.TokenFilePath); err != nil {
+			problems = append(problems, fmt.Sprintf("Auth.TokenFilePath is invalid: %v", err))
+		}
+	}
+	if c.Log.Path == "" && !c.Log.Stdout && !c.Log.Syslog.Enabled {
+		problems = append(problems, "at least one of Log.Path, Log.Stdout and Log.Syslog must be enabled")
+	}
+	if _, err := logrus.ParseLevel(c.Log.Level); err != nil {
+		problems = append(problems, fmt.Sprintf("Log.Level is invalid: %v", err))
+	}
+	if c.Log.Format != LogFormatJson && c.Log.Format != LogFormatText {
+		problems = append(problems, fmt.Sprintf("Log.Format must be %q or %q, got %q", LogFormatJson, LogFormatText, c.Log.Format))
+	}
+	if c.Log.Syslog.Enabled && (c.Log.Syslog.Network == "") != (c.Log.Syslog.Address == "") {
+		problems = append(problems, "Log.Syslog.Network and Log.Syslog.Address must be set together")
+	}
+	if c.Log.MaxSize < 0 || c.Log.MaxBackups < 0 || c.Log.MaxAge < 0 {
+		problems = append(problems, "Log.MaxSize, Log.MaxBackups and Log.MaxAge must not be negative")
+	}
+	if c.Jobs.MaxJobsPerRequest == 0 {
+		problems = append(problems, "Jobs.MaxJobsPerRequest must be greater than 0")
+	}
+	if c.Features.SubmitMode != SubmitModeGrpc && c.Features.SubmitMode != SubmitModeCbatch {
+		problems = append(problems, fmt.Sprintf("Features.SubmitMode must be %q or %q, got %q", SubmitModeGrpc, SubmitModeCbatch, c.Features.SubmitMode))
+	}
+	return problems
+}
+
+// 加载并校验适配器配置和Crane配置，适配器配置中设置的集群名、分区和CraneCtld地址优先
+// 配置有问题时返回的*ConfigError包含所有发现的问题
+func LoadConfig(configFilePath string, required bool) (*AdapterConfig, *Config, error) {
+	var problems []string
+	adapterConfig, err := ParseAdapterConfig(configFilePath, required)
+	if err != nil {
+		// 适配器配置无法解析时仍然按默认路径检查crane配置
+		problems = append(problems, err.Error())
+		adapterConfig = DefaultAdapterConfig()
+	} else {
+		problems = append(problems, adapterConfig.Validate()...)
+	}
+
+	config, err := ParseConfig(adapterConfig.CraneConfigPath)
+	if err != nil {
+		problems = append(problems, err.Error())
+		return nil, nil, &ConfigError{Problems: problems}
+	}
+	if adapterConfig.ClusterName != "" {
+		config.ClusterName = adapterConfig.ClusterName
+	}
+	if len(adapterConfig.Partitions) != 0 {
+		config.Partitions = []Partition{}
+		for _, name := range adapterConfig.Partitions {
+			config.Partitions = append(config.Partitions, Partition{Name: name})
+		}
+	}
+	problems = append(problems, config.Validate()...)
+	if len(problems) != 0 {
+		return nil, nil, &ConfigError{Problems: problems}
+	}
+	if adapterConfig.CraneCtldAddress == "" {
+		adapterConfig.CraneCtldAddress = fmt.Sprintf("%s:%s", config.ControlMachine, config.CraneCtldListenPort)
+	}
+	return adapterConfig, config, nil
+}
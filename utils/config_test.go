@@ -0,0 +1,150 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 在临时目录中写入配置文件，返回文件路径
+func writeConfigFile(t *testing.T, name string, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("write config failed: %v", err)
+	}
+	return path
+}
+
+const testCraneConfig = `
+ClusterName: crane
+ControlMachine: crane01
+CraneCtldListenPort: 10011
+Partitions:
+  - name: CPU
+    nodes: crane[01-04]
+  - name: GPU
+    nodes: crane[05-06]
+`
+
+func TestLoadConfigDefaults(t *testing.T) {
+	cranePath := writeConfigFile(t, "config.yaml", testCraneConfig)
+	adapterPath := writeConfigFile(t, "crane-adapter.yaml", "CraneConfigPath: "+cranePath+"\n")
+
+	adapterConfig, config, err := LoadConfig(adapterPath, true)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	assert.Equal(t, ":8972", adapterConfig.ListenAddress)
+	assert.Equal(t, "crane01:10011", adapterConfig.CraneCtldAddress)
+	assert.Equal(t, SubmitModeGrpc, adapterConfig.Features.SubmitMode)
+	assert.Equal(t, "server.log", adapterConfig.Log.Path)
+	assert.Equal(t, "crane", config.ClusterName)
+	assert.Len(t, config.Partitions, 2)
+}
+
+func TestLoadConfigOverrides(t *testing.T) {
+	cranePath := writeConfigFile(t, "config.yaml", testCraneConfig)
+	adapterPath := writeConfigFile(t, "crane-adapter.yaml", `
+CraneConfigPath: `+cranePath+`
+ListenAddress: 127.0.0.1:9000
+CraneCtldAddress: 10.0.0.1:10011
+ClusterName: hpc
+Partitions: [GPU]
+Features:
+  SubmitMode: cbatch
+`)
+
+	adapterConfig, config, err := LoadConfig(adapterPath, true)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	assert.Equal(t, "127.0.0.1:9000", adapterConfig.ListenAddress)
+	assert.Equal(t, "10.0.0.1:10011", adapterConfig.CraneCtldAddress)
+	assert.Equal(t, SubmitModeCbatch, adapterConfig.Features.SubmitMode)
+	assert.Equal(t, "hpc", config.ClusterName)
+	assert.Equal(t, []Partition{{Name: "GPU"}}, config.Partitions)
+}
+
+func TestLoadConfigMissingAdapterConfig(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "crane-adapter.yaml")
+
+	_, _, err := LoadConfig(missing, true)
+
+	assert.Error(t, err)
+}
+
+func TestLoadConfigReportsAllProblems(t *testing.T) {
+	cranePath := writeConfigFile(t, "config.yaml", `
+ClusterName: crane
+UseTls: true
+ServerCertFilePath: /nonexistent/server.crt
+`)
+	adapterPath := writeConfigFile(t, "crane-adapter.yaml", `
+CraneConfigPath: `+cranePath+`
+Features:
+  SubmitMode: sbatch
+`)
+
+	_, _, err := LoadConfig(adapterPath, true)
+
+	configError, ok := err.(*ConfigError)
+	if !assert.True(t, ok, "expected *ConfigError, got %v", err) {
+		return
+	}
+	assert.Len(t, configError.Problems, 7)
+	assert.Contains(t, err.Error(), "Features.SubmitMode")
+	assert.Contains(t, err.Error(), "ControlMachine is required")
+	assert.Contains(t, err.Error(), "CraneCtldListenPort is required")
+	assert.Contains(t, err.Error(), "Partitions must not be empty")
+	assert.Contains(t, err.Error(), "ServerCertFilePath is not readable")
+	assert.Contains(t, err.Error(), "ServerKeyFilePath is required when UseTls is true")
+	assert.Contains(t, err.Error(), "CaCertFilePath is required when UseTls is true")
+}
+
+func TestLoadConfigUnknownAdapterKey(t *testing.T) {
+	cranePath := writeConfigFile(t, "config.yaml", testCraneConfig)
+	adapterPath := writeConfigFile(t, "crane-adapter.yaml", "CraneConfigPath: "+cranePath+"\nListenAdress: :9000\n")
+
+	_, _, err := LoadConfig(adapterPath, true)
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "ListenAdress")
+}
+
+func TestLoadConfigInvalidLog(t *testing.T) {
+	cranePath := writeConfigFile(t, "config.yaml", testCraneConfig)
+	adapterPath := writeConfigFile(t, "crane-adapter.yaml", `
+CraneConfigPath: `+cranePath+`
+Log:
+  Path: ""
+  Stdout: false
+  Level: verbose
+  Format: xml
+`)
+
+	_, _, err := LoadConfig(adapterPath, true)
+
+	configError, ok := err.(*ConfigError)
+	if !assert.True(t, ok, "expected *ConfigError, got %v", err) {
+		return
+	}
+	assert.Len(t, configError.Problems, 3)
+	assert.Contains(t, err.Error(), "Log.Level is invalid")
+	assert.Contains(t, err.Error(), "Log.Format")
+	assert.Contains(t, err.Error(), "at least one of Log.Path")
+}
+
+func TestExampleAdapterConfig(t *testing.T) {
+	adapterConfig, err := ParseAdapterConfig("../crane-adapter.example.yaml", true)
+	if err != nil {
+		t.Fatalf("ParseAdapterConfig failed: %v", err)
+	}
+
+	assert.Empty(t, adapterConfig.Validate())
+	assert.Equal(t, DefaultAdapterConfig(), adapterConfig)
+}
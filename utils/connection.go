@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"context"
+	"time"
+
+	craneProtos "scow-crane-adapter/gen/crane"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
+)
+
+// 所有handler共用的CraneCtld连接
+// 连接断开后按退避策略自动重连，CraneCtld不可用期间的调用返回codes.Unavailable
+type CraneCtldConn struct {
+	craneProtos.CraneCtldClient
+	conn *grpc.ClientConn
+}
+
+// 连接CraneCtld，不等待连接建立，opts可以覆盖默认的keepalive和重连参数
+func DialCraneCtld(address string, creds credentials.TransportCredentials, opts ...grpc.DialOption) (*CraneCtldConn, error) {
+	dialOptions := []grpc.DialOption{
+		grpc.WithTransportCredentials(creds),
+		// 定期发送心跳，及时发现CraneCtld重启或网络中断
+		grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                30 * time.Second,
+			Timeout:             10 * time.Second,
+			PermitWithoutStream: true,
+		}),
+		// 重连间隔从1秒开始指数增长，最长30秒
+		grpc.WithConnectParams(grpc.ConnectParams{
+			Backoff: backoff.Config{
+				BaseDelay:  time.Second,
+				Multiplier: 1.6,
+				Jitter:     0.2,
+				MaxDelay:   30 * time.Second,
+			},
+			MinConnectTimeout: 5 * time.Second,
+		}),
+	}
+	conn, err := grpc.Dial(address, append(dialOptions, opts...)...)
+	if err != nil {
+		return nil, err
+	}
+	conn.Connect()
+	return &CraneCtldConn{
+		CraneCtldClient: craneProtos.NewCraneCtldClient(conn),
+		conn:            conn,
+	}, nil
+}
+
+// 当前的连接状态
+func (c *CraneCtldConn) State() connectivity.State {
+	return c.conn.GetState()
+}
+
+// 连接是否已经建立
+func (c *CraneCtldConn) Ready() bool {
+	return c.State() == connectivity.Ready
+}
+
+// 监听连接状态变化，每次变化时调用onChange，直到ctx结束或连接关闭
+func (c *CraneCtldConn) WatchState(ctx context.Context, onChange func(connectivity.State)) {
+	state := c.conn.GetState()
+	for state != connectivity.Shutdown {
+		if !c.conn.WaitForStateChange(ctx, state) {
+			return
+		}
+		state = c.conn.GetState()
+		// 空闲的连接不会主动重连，这里立即重连以便及时发现CraneCtld恢复
+		if state == connectivity.Idle {
+			c.conn.Connect()
+		}
+		onChange(state)
+	}
+}
+
+func (c *CraneCtldConn) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	craneProtos "scow-crane-adapter/gen/crane"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// 在address上启动一个未实现任何方法的CraneCtld
+func startCraneCtld(t *testing.T, address string) (*grpc.Server, string) {
+	t.Helper()
+	lis, err := net.Listen("tcp", address)
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	s := grpc.NewServer()
+	craneProtos.RegisterCraneCtldServer(s, craneProtos.UnimplementedCraneCtldServer{})
+	go s.Serve(lis)
+	return s, lis.Addr().String()
+}
+
+func waitForState(t *testing.T, conn *CraneCtldConn, want func(connectivity.State) bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !want(conn.State()) {
+		if time.Now().After(deadline) {
+			t.Fatalf("connection stuck in state %v", conn.State())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestCraneCtldConnReconnects(t *testing.T) {
+	server, address := startCraneCtld(t, "127.0.0.1:0")
+	conn, err := DialCraneCtld(address, insecure.NewCredentials(), grpc.WithConnectParams(grpc.ConnectParams{
+		Backoff:           backoff.Config{BaseDelay: 10 * time.Millisecond, Multiplier: 1, MaxDelay: 10 * time.Millisecond},
+		MinConnectTimeout: time.Second,
+	}))
+	if err != nil {
+		t.Fatalf("DialCraneCtld failed: %v", err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go conn.WatchState(ctx, func(connectivity.State) {})
+
+	waitForState(t, conn, func(s connectivity.State) bool { return s == connectivity.Ready })
+	_, err = conn.QueryEntityInfo(context.Background(), &craneProtos.QueryEntityInfoRequest{})
+	assert.Equal(t, codes.Unimplemented, status.Code(err))
+
+	// CraneCtld停止后调用立即返回Unavailable
+	server.Stop()
+	waitForState(t, conn, func(s connectivity.State) bool { return s == connectivity.TransientFailure })
+	assert.False(t, conn.Ready())
+	_, err = conn.QueryEntityInfo(context.Background(), &craneProtos.QueryEntityInfoRequest{})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+
+	// CraneCtld恢复后自动重连
+	server, _ = startCraneCtld(t, address)
+	defer server.Stop()
+	waitForState(t, conn, func(s connectivity.State) bool { return s == connectivity.Ready })
+	_, err = conn.QueryEntityInfo(context.Background(), &craneProtos.QueryEntityInfoRequest{})
+	assert.Equal(t, codes.Unimplemented, status.Code(err))
+}
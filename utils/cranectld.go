@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	craneProtos "scow-crane-adapter/gen/crane"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// 适配器用到的CraneCtld接口
+// craneProtos.CraneCtldClient满足该接口，测试时可以替换成内存中的fake实现
+type CraneCtldClient interface {
+	SubmitBatchTask(ctx context.Context, in *craneProtos.SubmitBatchTaskRequest, opts ...grpc.CallOption) (*craneProtos.SubmitBatchTaskReply, error)
+	CancelTask(ctx context.Context, in *craneProtos.CancelTaskRequest, opts ...grpc.CallOption) (*craneProtos.CancelTaskReply, error)
+	QueryPartitionInfo(ctx context.Context, in *craneProtos.QueryPartitionInfoRequest, opts ...grpc.CallOption) (*craneProtos.QueryPartitionInfoReply, error)
+	ModifyTask(ctx context.Context, in *craneProtos.ModifyTaskRequest, opts ...grpc.CallOption) (*craneProtos.ModifyTaskReply, error)
+	AddAccount(ctx context.Context, in *craneProtos.AddAccountRequest, opts ...grpc.CallOption) (*craneProtos.AddAccountReply, error)
+	AddUser(ctx context.Context, in *craneProtos.AddUserRequest, opts ...grpc.CallOption) (*craneProtos.AddUserReply, error)
+	QueryEntityInfo(ctx context.Context, in *craneProtos.QueryEntityInfoRequest, opts ...grpc.CallOption) (*craneProtos.QueryEntityInfoReply, error)
+	DeleteEntity(ctx context.Context, in *craneProtos.DeleteEntityRequest, opts ...grpc.CallOption) (*craneProtos.DeleteEntityReply, error)
+	BlockAccountOrUser(ctx context.Context, in *craneProtos.BlockAccountOrUserRequest, opts ...grpc.CallOption) (*craneProtos.BlockAccountOrUserReply, error)
+	QueryClusterInfo(ctx context.Context, in *craneProtos.QueryClusterInfoRequest, opts ...grpc.CallOption) (*craneProtos.QueryClusterInfoReply, error)
+	QueryTasksInfo(ctx context.Context, in *craneProtos.QueryTasksInfoRequest, opts ...grpc.CallOption) (*craneProtos.QueryTasksInfoReply, error)
+}
+
+// CraneCtld证书中的服务名，与Crane前端一致为ControlMachine加上DomainSuffix
+func CraneCtldServerName(config *Config) string {
+	if config.DomainSuffix == "" {
+		return config.ControlMachine
+	}
+	return config.ControlMachine + "." + strings.TrimPrefix(config.DomainSuffix, ".")
+}
+
+// 根据Crane配置创建连接CraneCtld的传输凭证
+// UseTls为true时使用CaCertFilePath校验CraneCtld的证书，并以ServerCertFilePath和ServerKeyFilePath作为客户端证书
+func CraneCtldCredentials(config *Config) (credentials.TransportCredentials, error) {
+	if !config.UseTls {
+		return insecure.NewCredentials(), nil
+	}
+	caPem, err := ioutil.ReadFile(config.CaCertFilePath)
+	if err != nil {
+		return nil, fmt.Errorf("read crane ca: %w", err)
+	}
+	rootCAs := x509.NewCertPool()
+	if !rootCAs.AppendCertsFromPEM(caPem) {
+		return nil, fmt.Errorf("no certificate found in crane ca %s", config.CaCertFilePath)
+	}
+	cert, err := tls.LoadX509KeyPair(config.ServerCertFilePath, config.ServerKeyFilePath)
+	if err != nil {
+		return nil, fmt.Errorf("load crane certificate: %w", err)
+	}
+	return credentials.NewTLS(&tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      rootCAs,
+		ServerName:   CraneCtldServerName(config),
+	}), nil
+}
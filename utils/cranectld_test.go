@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCraneCtldServerName(t *testing.T) {
+	tests := []struct {
+		controlMachine string
+		domainSuffix   string
+		want           string
+	}{
+		{"crane01", "", "crane01"},
+		{"crane01", "crane.local", "crane01.crane.local"},
+		{"crane01", ".crane.local", "crane01.crane.local"},
+	}
+	for _, tt := range tests {
+		config := &Config{ControlMachine: tt.controlMachine, DomainSuffix: tt.domainSuffix}
+		assert.Equal(t, tt.want, CraneCtldServerName(config))
+	}
+}
+
+func TestCraneCtldCredentialsInsecure(t *testing.T) {
+	creds, err := CraneCtldCredentials(&Config{ControlMachine: "crane01"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "insecure", creds.Info().SecurityProtocol)
+}
+
+func TestCraneCtldCredentialsTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, "ca", nil)
+	server := newTestCert(t, "crane01.crane.local", ca)
+	client := newTestCert(t, "crane-client", ca)
+	config := &Config{
+		ControlMachine:     "crane01",
+		DomainSuffix:       "crane.local",
+		UseTls:             true,
+		ServerCertFilePath: filepath.Join(dir, "server.crt"),
+		ServerKeyFilePath:  filepath.Join(dir, "server.key"),
+		CaCertFilePath:     filepath.Join(dir, "ca.crt"),
+	}
+	for path, content := range map[string][]byte{
+		config.ServerCertFilePath: client.certPem,
+		config.ServerKeyFilePath:  client.keyPem,
+		config.CaCertFilePath:     ca.certPem,
+	} {
+		if err := os.WriteFile(path, content, 0600); err != nil {
+			t.Fatalf("write %s failed: %v", path, err)
+		}
+	}
+	creds, err := CraneCtldCredentials(config)
+	if err != nil {
+		t.Fatalf("CraneCtldCredentials failed: %v", err)
+	}
+
+	addr := serveTLS(t, &tls.Config{Certificates: []tls.Certificate{server.tlsCertificate(t)}})
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, _, err = creds.ClientHandshake(ctx, addr, conn)
+	assert.NoError(t, err)
+
+	// 证书中的服务名与ControlMachine+DomainSuffix不一致时握手失败
+	config.DomainSuffix = "other.local"
+	creds, err = CraneCtldCredentials(config)
+	if err != nil {
+		t.Fatalf("CraneCtldCredentials failed: %v", err)
+	}
+	conn2, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer conn2.Close()
+	_, _, err = creds.ClientHandshake(ctx, addr, conn2)
+	assert.Error(t, err)
+}
+
+func TestCraneCtldCredentialsMissingCa(t *testing.T) {
+	_, err := CraneCtldCredentials(&Config{ControlMachine: "crane01", UseTls: true, CaCertFilePath: "/nonexistent/ca.crt"})
+
+	assert.Error(t, err)
+}
@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"regexp"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// CraneCtld的回复只有Ok和Reason文本，这里按Reason的内容翻译成SCOW适配器接口定义的错误码
+// 规则按顺序匹配，更具体的规则需要放在前面
+type craneReasonRule struct {
+	pattern *regexp.Regexp
+	code    codes.Code
+	reason  string
+}
+
+// 匹配"Unknown account 'a'"、"Can't find account a!"、"The account a doesn't exist in the database."等
+func notFoundPattern(entity string) *regexp.Regexp {
+	return regexp.MustCompile(`(?i)(?:unknown|can't find|cannot find|no such) ` + entity + `\b|` +
+		entity + ` \S+ (?:is not|was not|does not|doesn't|not) (?:exist|found)`)
+}
+
+var craneReasonRules = []craneReasonRule{
+	{regexp.MustCompile(`(?i)permission|not authorized|insufficient privilege`), codes.PermissionDenied, "PERMISSION_DENIED"},
+	// 用户存在但不在该账户中，需要先于USER_NOT_FOUND匹配
+	{regexp.MustCompile(`(?i)user \S+ (?:is not|does not|doesn't|not) (?:exist |belong )?(?:in|to|under) (?:the )?account|` +
+		`unknown user \S+ (?:in|under) (?:the )?account`), codes.NotFound, "ASSOCIATION_NOT_EXISTS"},
+	{regexp.MustCompile(`(?i)user \S+ already exists?`), codes.AlreadyExists, "USER_ALREADY_EXISTS"},
+	{regexp.MustCompile(`(?i)account \S+ already exists?`), codes.AlreadyExists, "ACCOUNT_ALREADY_EXISTS"},
+	{notFoundPattern("account"), codes.NotFound, "ACCOUNT_NOT_FOUND"},
+	{notFoundPattern("user"), codes.NotFound, "USER_NOT_FOUND"},
+	{notFoundPattern("qos"), codes.NotFound, "QOS_NOT_FOUND"},
+	{notFoundPattern("partition"), codes.NotFound, "PARTITION_NOT_FOUND"},
+	{regexp.MustCompile(`(?i)(?:task|job) \S+ (?:is not|was not|does not|doesn't|not) (?:exist|found|running or pending)|` +
+		`(?:unknown|can't find|cannot find|no such) (?:task|job)\b`), codes.NotFound, "JOB_NOT_FOUND"},
+}
+
+// TranslateCraneReason 根据CraneCtld返回的失败原因查找对应的SCOW错误码，没有匹配的规则时ok为false
+func TranslateCraneReason(craneReason string) (code codes.Code, reason string, ok bool) {
+	for _, rule := range craneReasonRules {
+		if rule.pattern.MatchString(craneReason) {
+			return rule.code, rule.reason, true
+		}
+	}
+	return codes.Unknown, "", false
+}
+
+// CraneReplyError 将CraneCtld返回Ok=false时的Reason转换为SCOW错误，无法识别时返回CRANE_INTERNAL_ERROR
+func CraneReplyError(craneReason string) error {
+	message := craneReason
+	if message == "" {
+		message = "Crane service internal error."
+	}
+	code, reason, ok := TranslateCraneReason(craneReason)
+	if !ok {
+		return RichError(codes.Internal, "CRANE_INTERNAL_ERROR", message)
+	}
+	return RichError(code, reason, message)
+}
+
+// CraneCallError 将调用CraneCtld本身失败的错误转换为SCOW错误
+// 已经带有ErrorInfo的错误原样返回，对调用方有意义的状态码(参数错误、不存在、无权限等)保留，
+// 其余(非gRPC错误、Internal、Unknown等)都视为CraneCtld不可用
+func CraneCallError(err error) error {
+	st := status.Convert(err)
+	for _, detail := range st.Details() {
+		if _, ok := detail.(*errdetails.ErrorInfo); ok {
+			return err
+		}
+	}
+	switch st.Code() {
+	case codes.DeadlineExceeded, codes.Canceled,
+		codes.InvalidArgument, codes.NotFound, codes.AlreadyExists, codes.PermissionDenied,
+		codes.Unauthenticated, codes.FailedPrecondition, codes.ResourceExhausted, codes.OutOfRange,
+		codes.Unimplemented:
+		return RichError(st.Code(), "CRANE_CALL_FAILED", err.Error())
+	default:
+		return RichError(codes.Unavailable, "CRANE_CALL_FAILED", err.Error())
+	}
+}
@@ -0,0 +1,93 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestTranslateCraneReason(t *testing.T) {
+	tests := []struct {
+		craneReason string
+		code        codes.Code
+		reason      string
+	}{
+		{"Permission denied.", codes.PermissionDenied, "PERMISSION_DENIED"},
+		{"Your permission is insufficient.", codes.PermissionDenied, "PERMISSION_DENIED"},
+		{"User a doesn't exist in account b.", codes.NotFound, "ASSOCIATION_NOT_EXISTS"},
+		{"Unknown user 'a' in account 'b'.", codes.NotFound, "ASSOCIATION_NOT_EXISTS"},
+		{"User 'a' doesn't belong to account 'b'.", codes.NotFound, "ASSOCIATION_NOT_EXISTS"},
+		{"The user a already exists in account b.", codes.AlreadyExists, "USER_ALREADY_EXISTS"},
+		{"The account a already exists in the database.", codes.AlreadyExists, "ACCOUNT_ALREADY_EXISTS"},
+		{"Can't find account a!", codes.NotFound, "ACCOUNT_NOT_FOUND"},
+		{"Unknown account 'a'.", codes.NotFound, "ACCOUNT_NOT_FOUND"},
+		{"The account a doesn't exist in the database.", codes.NotFound, "ACCOUNT_NOT_FOUND"},
+		{"Account 'a' not existed.", codes.NotFound, "ACCOUNT_NOT_FOUND"},
+		{"Can't find user a!", codes.NotFound, "USER_NOT_FOUND"},
+		{"User 'a' doesn't exist.", codes.NotFound, "USER_NOT_FOUND"},
+		{"Qos 'high' doesn't exist.", codes.NotFound, "QOS_NOT_FOUND"},
+		{"Partition 'GPU' doesn't exist!", codes.NotFound, "PARTITION_NOT_FOUND"},
+		{"Task #5 was not found in running or pending queue.", codes.NotFound, "JOB_NOT_FOUND"},
+		{"Task #5 is not running or pending.", codes.NotFound, "JOB_NOT_FOUND"},
+		{"Task id doesn't exist!", codes.NotFound, "JOB_NOT_FOUND"},
+	}
+	for _, tt := range tests {
+		code, reason, ok := TranslateCraneReason(tt.craneReason)
+		if assert.True(t, ok, tt.craneReason) {
+			assert.Equal(t, tt.code, code, tt.craneReason)
+			assert.Equal(t, tt.reason, reason, tt.craneReason)
+		}
+	}
+}
+
+func TestTranslateCraneReasonUnknown(t *testing.T) {
+	for _, craneReason := range []string{"", "Account name empty.", "The account 'a' is blocked."} {
+		_, _, ok := TranslateCraneReason(craneReason)
+		assert.False(t, ok, craneReason)
+	}
+}
+
+func TestCraneReplyError(t *testing.T) {
+	err := CraneReplyError("Can't find account a!")
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.Equal(t, "ACCOUNT_NOT_FOUND", ErrorReason(err))
+	assert.Equal(t, "Can't find account a!", status.Convert(err).Message())
+
+	err = CraneReplyError("")
+	assert.Equal(t, codes.Internal, status.Code(err))
+	assert.Equal(t, "CRANE_INTERNAL_ERROR", ErrorReason(err))
+	assert.Equal(t, "Crane service internal error.", status.Convert(err).Message())
+}
+
+func TestCraneCallError(t *testing.T) {
+	tests := []struct {
+		err  error
+		code codes.Code
+	}{
+		{errors.New("connection refused"), codes.Unavailable},
+		{status.Error(codes.Unavailable, "connection refused"), codes.Unavailable},
+		{status.Error(codes.Internal, "stream terminated"), codes.Unavailable},
+		{status.Error(codes.Unknown, "unknown"), codes.Unavailable},
+		{status.Error(codes.InvalidArgument, "bad request"), codes.InvalidArgument},
+		{status.Error(codes.NotFound, "not found"), codes.NotFound},
+		{status.Error(codes.AlreadyExists, "already exists"), codes.AlreadyExists},
+		{status.Error(codes.PermissionDenied, "permission denied"), codes.PermissionDenied},
+		{status.Error(codes.Unauthenticated, "unauthenticated"), codes.Unauthenticated},
+		{status.Error(codes.FailedPrecondition, "failed precondition"), codes.FailedPrecondition},
+		{status.FromContextError(context.DeadlineExceeded).Err(), codes.DeadlineExceeded},
+		{status.FromContextError(context.Canceled).Err(), codes.Canceled},
+	}
+	for _, tt := range tests {
+		err := CraneCallError(tt.err)
+		assert.Equal(t, tt.code, status.Code(err), tt.err.Error())
+		assert.Equal(t, "CRANE_CALL_FAILED", ErrorReason(err), tt.err.Error())
+	}
+
+	// 已经翻译过的错误原样返回
+	replyErr := CraneReplyError("Can't find account a!")
+	assert.Equal(t, replyErr, CraneCallError(replyErr))
+}
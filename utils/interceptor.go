@@ -0,0 +1,21 @@
+package utils
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// 请求没有设置超时时使用默认超时，超时后handler中对CraneCtld的调用和子进程随之取消
+// timeout为0时不设置超时
+func DefaultTimeoutInterceptor(timeout time.Duration) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if _, ok := ctx.Deadline(); !ok && timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		return handler(ctx, req)
+	}
+}
@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+)
+
+// 调用拦截器并返回handler收到的ctx的截止时间
+func handlerDeadline(ctx context.Context, interceptor grpc.UnaryServerInterceptor) (time.Time, bool) {
+	var deadline time.Time
+	var ok bool
+	interceptor(ctx, nil, &grpc.UnaryServerInfo{}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		deadline, ok = ctx.Deadline()
+		return nil, nil
+	})
+	return deadline, ok
+}
+
+func TestDefaultTimeoutInterceptor(t *testing.T) {
+	deadline, ok := handlerDeadline(context.Background(), DefaultTimeoutInterceptor(time.Minute))
+	assert.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), deadline, time.Second)
+
+	// 请求自带的截止时间不会被覆盖
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	deadline, ok = handlerDeadline(ctx, DefaultTimeoutInterceptor(time.Minute))
+	assert.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), deadline, time.Second)
+
+	_, ok = handlerDeadline(context.Background(), DefaultTimeoutInterceptor(0))
+	assert.False(t, ok)
+}
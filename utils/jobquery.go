@@ -0,0 +1,97 @@
+package utils
+
+import (
+	craneProtos "scow-crane-adapter/gen/crane"
+	protos "scow-crane-adapter/gen/go"
+)
+
+// 构造查询作业的QueryTasksInfoRequest，各个筛选条件可以任意组合，未设置的条件不筛选
+type TasksQueryBuilder struct {
+	request *craneProtos.QueryTasksInfoRequest
+}
+
+// NewTasksQuery 创建包含已结束作业的查询，numLimit为最多返回的作业数
+func NewTasksQuery(numLimit uint32) *TasksQueryBuilder {
+	return &TasksQueryBuilder{request: &craneProtos.QueryTasksInfoRequest{
+		OptionIncludeCompletedTasks: true,
+		NumLimit:                    numLimit,
+	}}
+}
+
+// 按SCOW的作业状态筛选
+func (b *TasksQueryBuilder) States(states []string) *TasksQueryBuilder {
+	b.request.FilterTaskStates = GetCraneStatesList(states)
+	return b
+}
+
+func (b *TasksQueryBuilder) Users(users []string) *TasksQueryBuilder {
+	b.request.FilterUsers = users
+	return b
+}
+
+func (b *TasksQueryBuilder) Accounts(accounts []string) *TasksQueryBuilder {
+	b.request.FilterAccounts = accounts
+	return b
+}
+
+func (b *TasksQueryBuilder) JobIds(jobIds []uint32) *TasksQueryBuilder {
+	b.request.FilterTaskIds = jobIds
+	return b
+}
+
+func (b *TasksQueryBuilder) Partitions(partitions []string) *TasksQueryBuilder {
+	b.request.FilterPartitions = partitions
+	return b
+}
+
+// 按结束时间筛选，时间范围的两端都可以不设置
+func (b *TasksQueryBuilder) EndTime(timeRange *protos.TimeRange) *TasksQueryBuilder {
+	b.request.FilterEndTimeInterval = timeInterval(timeRange)
+	return b
+}
+
+// 按开始时间筛选，时间范围的两端都可以不设置
+func (b *TasksQueryBuilder) StartTime(timeRange *protos.TimeRange) *TasksQueryBuilder {
+	b.request.FilterStartTimeInterval = timeInterval(timeRange)
+	return b
+}
+
+// 按提交时间筛选，时间范围的两端都可以不设置
+func (b *TasksQueryBuilder) SubmitTime(timeRange *protos.TimeRange) *TasksQueryBuilder {
+	b.request.FilterSubmitTimeInterval = timeInterval(timeRange)
+	return b
+}
+
+// 按GetJobs请求中的筛选条件设置查询，作业名的子串匹配CraneCtld不支持，需要调用方自行处理
+func (b *TasksQueryBuilder) Filter(filter *protos.GetJobsRequest_Filter) *TasksQueryBuilder {
+	if filter == nil {
+		return b
+	}
+	return b.States(filter.States).
+		Users(filter.Users).
+		Accounts(filter.Accounts).
+		JobIds(filter.JobIds).
+		Partitions(filter.Partitions).
+		EndTime(filter.EndTime).
+		StartTime(filter.StartTime).
+		SubmitTime(filter.SubmitTime)
+}
+
+func (b *TasksQueryBuilder) Build() *craneProtos.QueryTasksInfoRequest {
+	return b.request
+}
+
+// 将SCOW的时间范围转换为Crane的时间区间，未设置或为0的一端不限制，两端都不限制时返回nil
+func timeInterval(timeRange *protos.TimeRange) *craneProtos.TimeInterval {
+	interval := &craneProtos.TimeInterval{}
+	if timeRange.GetStartTime().GetSeconds() != 0 {
+		interval.LowerBound = timeRange.GetStartTime()
+	}
+	if timeRange.GetEndTime().GetSeconds() != 0 {
+		interval.UpperBound = timeRange.GetEndTime()
+	}
+	if interval.LowerBound == nil && interval.UpperBound == nil {
+		return nil
+	}
+	return interval
+}
@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	craneProtos "scow-crane-adapter/gen/crane"
+	protos "scow-crane-adapter/gen/go"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestTimeInterval(t *testing.T) {
+	lower := timestamppb.New(time.Unix(1700000000, 0))
+	upper := timestamppb.New(time.Unix(1700086400, 0))
+	tests := []struct {
+		name      string
+		timeRange *protos.TimeRange
+		interval  *craneProtos.TimeInterval
+	}{
+		{"unset", nil, nil},
+		{"empty", &protos.TimeRange{}, nil},
+		{"zero bounds", &protos.TimeRange{StartTime: &timestamppb.Timestamp{}, EndTime: &timestamppb.Timestamp{}}, nil},
+		{"lower bound only", &protos.TimeRange{StartTime: lower}, &craneProtos.TimeInterval{LowerBound: lower}},
+		{"upper bound only", &protos.TimeRange{EndTime: upper}, &craneProtos.TimeInterval{UpperBound: upper}},
+		{"both bounds", &protos.TimeRange{StartTime: lower, EndTime: upper}, &craneProtos.TimeInterval{LowerBound: lower, UpperBound: upper}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.interval, timeInterval(tt.timeRange))
+		})
+	}
+}
+
+func TestTasksQueryBuilder(t *testing.T) {
+	lower := timestamppb.New(time.Unix(1700000000, 0))
+	upper := timestamppb.New(time.Unix(1700086400, 0))
+
+	request := NewTasksQuery(100).Filter(&protos.GetJobsRequest_Filter{
+		Users:      []string{"demo"},
+		Accounts:   []string{"a_admin"},
+		States:     []string{"RUNNING", "COMPLETED"},
+		JobIds:     []uint32{1, 2},
+		JobName:    proto.String("train"),
+		Partitions: []string{"CPU"},
+		EndTime:    &protos.TimeRange{EndTime: upper},
+		StartTime:  &protos.TimeRange{StartTime: lower},
+		SubmitTime: &protos.TimeRange{StartTime: lower, EndTime: upper},
+	}).Build()
+
+	assert.True(t, request.OptionIncludeCompletedTasks)
+	assert.Equal(t, uint32(100), request.NumLimit)
+	assert.Equal(t, []string{"demo"}, request.FilterUsers)
+	assert.Equal(t, []string{"a_admin"}, request.FilterAccounts)
+	assert.Equal(t, []craneProtos.TaskStatus{craneProtos.TaskStatus_Running, craneProtos.TaskStatus_Completed}, request.FilterTaskStates)
+	assert.Equal(t, []uint32{1, 2}, request.FilterTaskIds)
+	// CraneCtld只支持精确匹配作业名，子串匹配由调用方处理
+	assert.Empty(t, request.FilterTaskNames)
+	assert.Equal(t, []string{"CPU"}, request.FilterPartitions)
+	assert.Equal(t, &craneProtos.TimeInterval{UpperBound: upper}, request.FilterEndTimeInterval)
+	assert.Equal(t, &craneProtos.TimeInterval{LowerBound: lower}, request.FilterStartTimeInterval)
+	assert.Equal(t, &craneProtos.TimeInterval{LowerBound: lower, UpperBound: upper}, request.FilterSubmitTimeInterval)
+}
+
+func TestTasksQueryBuilderNoFilter(t *testing.T) {
+	request := NewTasksQuery(100).Filter(nil).Build()
+
+	assert.Equal(t, &craneProtos.QueryTasksInfoRequest{OptionIncludeCompletedTasks: true, NumLimit: 100}, request)
+}
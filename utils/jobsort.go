@@ -0,0 +1,103 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+
+	protos "scow-crane-adapter/gen/go"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// 比较两个作业的某个字段，a小于、等于、大于b时分别返回负数、0、正数
+type jobInfoComparator func(a, b *protos.JobInfo) int
+
+// 可以排序的JobInfo字段，键为SCOW请求中使用的字段名
+// 可选字段未设置时视为零值，时间字段未设置时排在最前
+var jobInfoComparators = map[string]jobInfoComparator{
+	"job_id":             func(a, b *protos.JobInfo) int { return compareValues(a.JobId, b.JobId) },
+	"name":               func(a, b *protos.JobInfo) int { return compareValues(a.Name, b.Name) },
+	"account":            func(a, b *protos.JobInfo) int { return compareValues(a.Account, b.Account) },
+	"user":               func(a, b *protos.JobInfo) int { return compareValues(a.User, b.User) },
+	"partition":          func(a, b *protos.JobInfo) int { return compareValues(a.Partition, b.Partition) },
+	"qos":                func(a, b *protos.JobInfo) int { return compareValues(a.Qos, b.Qos) },
+	"state":              func(a, b *protos.JobInfo) int { return compareValues(a.State, b.State) },
+	"cpus_req":           func(a, b *protos.JobInfo) int { return compareValues(a.GetCpusReq(), b.GetCpusReq()) },
+	"mem_req_mb":         func(a, b *protos.JobInfo) int { return compareValues(a.GetMemReqMb(), b.GetMemReqMb()) },
+	"nodes_req":          func(a, b *protos.JobInfo) int { return compareValues(a.GetNodesReq(), b.GetNodesReq()) },
+	"time_limit_minutes": func(a, b *protos.JobInfo) int { return compareValues(a.TimeLimitMinutes, b.TimeLimitMinutes) },
+	"submit_time":        func(a, b *protos.JobInfo) int { return compareTimestamps(a.SubmitTime, b.SubmitTime) },
+	"start_time":         func(a, b *protos.JobInfo) int { return compareTimestamps(a.StartTime, b.StartTime) },
+	"end_time":           func(a, b *protos.JobInfo) int { return compareTimestamps(a.EndTime, b.EndTime) },
+	"elapsed_seconds":    func(a, b *protos.JobInfo) int { return compareValues(a.GetElapsedSeconds(), b.GetElapsedSeconds()) },
+	"reason":             func(a, b *protos.JobInfo) int { return compareValues(a.GetReason(), b.GetReason()) },
+	"node_list":          func(a, b *protos.JobInfo) int { return compareValues(a.GetNodeList(), b.GetNodeList()) },
+	"gpus_alloc":         func(a, b *protos.JobInfo) int { return compareValues(a.GetGpusAlloc(), b.GetGpusAlloc()) },
+	"cpus_alloc":         func(a, b *protos.JobInfo) int { return compareValues(a.GetCpusAlloc(), b.GetCpusAlloc()) },
+	"mem_alloc_mb":       func(a, b *protos.JobInfo) int { return compareValues(a.GetMemAllocMb(), b.GetMemAllocMb()) },
+	"nodes_alloc":        func(a, b *protos.JobInfo) int { return compareValues(a.GetNodesAlloc(), b.GetNodesAlloc()) },
+	"working_directory":  func(a, b *protos.JobInfo) int { return compareValues(a.WorkingDirectory, b.WorkingDirectory) },
+}
+
+type orderedValue interface {
+	~int32 | ~int64 | ~uint32 | ~uint64 | ~string
+}
+
+func compareValues[T orderedValue](a, b T) int {
+	if a < b {
+		return -1
+	}
+	if a > b {
+		return 1
+	}
+	return 0
+}
+
+func compareTimestamps(a, b *timestamppb.Timestamp) int {
+	if a == nil || b == nil {
+		return compareValues(boolToInt32(a != nil), boolToInt32(b != nil))
+	}
+	if c := compareValues(a.GetSeconds(), b.GetSeconds()); c != 0 {
+		return c
+	}
+	return compareValues(a.GetNanos(), b.GetNanos())
+}
+
+func boolToInt32(value bool) int32 {
+	if value {
+		return 1
+	}
+	return 0
+}
+
+// CheckJobSortField 检查是否支持按该字段排序作业，field为空时按job_id排序
+func CheckJobSortField(field string) error {
+	if _, ok := jobInfoComparators[field]; !ok && field != "" {
+		return RichError(codes.InvalidArgument, "INVALID_SORT_FIELD", fmt.Sprintf("Jobs can not be sorted by %q.", field))
+	}
+	return nil
+}
+
+// SortJobInfo 按SCOW请求中的字段名排序作业，field为空时按job_id排序
+// 字段相同的作业按job_id排序，保证分页结果稳定；不支持的字段返回InvalidArgument
+func SortJobInfo(field string, order protos.SortInfo_SortOrder, jobInfo []*protos.JobInfo) error {
+	if err := CheckJobSortField(field); err != nil {
+		return err
+	}
+	if field == "" {
+		field = "job_id"
+	}
+	compare := jobInfoComparators[field]
+	sort.SliceStable(jobInfo, func(i, j int) bool {
+		c := compare(jobInfo[i], jobInfo[j])
+		if c == 0 {
+			c = compareValues(jobInfo[i].JobId, jobInfo[j].JobId)
+		}
+		if order == protos.SortInfo_DESC {
+			return c > 0
+		}
+		return c < 0
+	})
+	return nil
+}
@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	protos "scow-crane-adapter/gen/go"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func jobIds(jobInfo []*protos.JobInfo) []uint32 {
+	var ids []uint32
+	for _, job := range jobInfo {
+		ids = append(ids, job.JobId)
+	}
+	return ids
+}
+
+func TestSortJobInfo(t *testing.T) {
+	at := func(seconds int64) *timestamppb.Timestamp { return timestamppb.New(time.Unix(seconds, 0)) }
+	newJobs := func() []*protos.JobInfo {
+		return []*protos.JobInfo{
+			{JobId: 3, Name: "b", TimeLimitMinutes: 60, ElapsedSeconds: proto.Int64(30), CpusAlloc: proto.Int32(4), StartTime: at(200)},
+			{JobId: 1, Name: "c", TimeLimitMinutes: 10, ElapsedSeconds: proto.Int64(10), CpusAlloc: proto.Int32(4), StartTime: at(100)},
+			{JobId: 2, Name: "a", TimeLimitMinutes: 30},
+		}
+	}
+	tests := []struct {
+		field  string
+		order  protos.SortInfo_SortOrder
+		jobIds []uint32
+	}{
+		{"", protos.SortInfo_ASC, []uint32{1, 2, 3}},
+		{"job_id", protos.SortInfo_ASC, []uint32{1, 2, 3}},
+		{"job_id", protos.SortInfo_DESC, []uint32{3, 2, 1}},
+		{"name", protos.SortInfo_ASC, []uint32{2, 3, 1}},
+		{"time_limit_minutes", protos.SortInfo_ASC, []uint32{1, 2, 3}},
+		{"time_limit_minutes", protos.SortInfo_DESC, []uint32{3, 2, 1}},
+		// 未设置的可选字段视为0
+		{"elapsed_seconds", protos.SortInfo_ASC, []uint32{2, 1, 3}},
+		// 字段相同时按job_id排序
+		{"cpus_alloc", protos.SortInfo_ASC, []uint32{2, 1, 3}},
+		{"cpus_alloc", protos.SortInfo_DESC, []uint32{3, 1, 2}},
+		// 未设置的时间排在最前
+		{"start_time", protos.SortInfo_ASC, []uint32{2, 1, 3}},
+		{"start_time", protos.SortInfo_DESC, []uint32{3, 1, 2}},
+	}
+	for _, tt := range tests {
+		jobs := newJobs()
+		err := SortJobInfo(tt.field, tt.order, jobs)
+		if assert.NoError(t, err) {
+			assert.Equal(t, tt.jobIds, jobIds(jobs), "%s %s", tt.field, tt.order)
+		}
+	}
+}
+
+func TestSortJobInfoUnknownField(t *testing.T) {
+	jobs := []*protos.JobInfo{{JobId: 2}, {JobId: 1}}
+
+	err := SortJobInfo("JobId", protos.SortInfo_ASC, jobs)
+
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Equal(t, "INVALID_SORT_FIELD", ErrorReason(err))
+	assert.Equal(t, []uint32{2, 1}, jobIds(jobs))
+}
+
+func TestJobInfoComparatorsCoverAllFields(t *testing.T) {
+	fields := (&protos.JobInfo{}).ProtoReflect().Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		name := string(fields.Get(i).Name())
+		assert.Contains(t, jobInfoComparators, name)
+	}
+	assert.Len(t, jobInfoComparators, fields.Len())
+}
@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"io"
+	"log/syslog"
+	"os"
+
+	"github.com/sirupsen/logrus"
+	logrus_syslog "github.com/sirupsen/logrus/hooks/syslog"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// 根据配置创建日志，返回的函数用于在退出前关闭日志文件
+func NewLogger(c LogConfig) (*logrus.Logger, func(), error) {
+	logger := logrus.New()
+	level, err := logrus.ParseLevel(c.Level)
+	if err != nil {
+		return nil, nil, err
+	}
+	logger.SetLevel(level)
+	if c.Format == LogFormatText {
+		logger.SetFormatter(&logrus.TextFormatter{FullTimestamp: true})
+	} else {
+		logger.SetFormatter(&logrus.JSONFormatter{})
+	}
+
+	var writers []io.Writer
+	closeLog := func() {}
+	if c.Stdout {
+		writers = append(writers, os.Stdout)
+	}
+	if c.Path != "" {
+		logFile := &lumberjack.Logger{
+			Filename:   c.Path,       // 日志文件路径
+			MaxSize:    c.MaxSize,    // 日志文件的最大大小（以MB为单位）
+			MaxBackups: c.MaxBackups, // 保留的旧日志文件数量
+			MaxAge:     c.MaxAge,     // 保留的旧日志文件的最大天数
+			LocalTime:  true,         // 使用本地时间戳
+			Compress:   c.Compress,   // 是否压缩旧日志文件
+		}
+		writers = append(writers, logFile)
+		closeLog = func() { logFile.Close() }
+	}
+	logger.SetOutput(io.MultiWriter(writers...))
+
+	if c.Syslog.Enabled {
+		// syslog的级别由hook根据日志级别决定，这里的优先级只是默认值
+		hook, err := logrus_syslog.NewSyslogHook(c.Syslog.Network, c.Syslog.Address, syslog.LOG_INFO|syslog.LOG_DAEMON, c.Syslog.Tag)
+		if err != nil {
+			closeLog()
+			return nil, nil, err
+		}
+		logger.AddHook(hook)
+	}
+	return logger, closeLog, nil
+}
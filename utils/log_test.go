@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewLogger(t *testing.T) {
+	c := DefaultAdapterConfig().Log
+	c.Path = filepath.Join(t.TempDir(), "adapter.log")
+	c.Stdout = false
+	c.Level = "warn"
+	c.Format = LogFormatText
+
+	logger, closeLog, err := NewLogger(c)
+	if err != nil {
+		t.Fatalf("NewLogger failed: %v", err)
+	}
+	logger.Info("hidden message")
+	logger.WithField("request_id", "req-1").Warn("visible message")
+	closeLog()
+
+	assert.Equal(t, logrus.WarnLevel, logger.GetLevel())
+	content, _ := ioutil.ReadFile(c.Path)
+	assert.NotContains(t, string(content), "hidden message")
+	assert.Contains(t, string(content), `msg="visible message" request_id=req-1`)
+}
+
+func TestNewLoggerInvalidLevel(t *testing.T) {
+	c := DefaultAdapterConfig().Log
+	c.Level = "verbose"
+
+	_, _, err := NewLogger(c)
+
+	assert.Error(t, err)
+}
@@ -0,0 +1,157 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// 请求id的metadata键，SCOW传入时沿用，否则由适配器生成，并通过响应头返回
+const RequestIdMetadataKey = "x-request-id"
+
+// 日志中字符串字段的最大长度，超过的部分会被截断
+const maxLoggedStringLength = 256
+
+// 日志中需要隐去内容的字段，只记录长度
+var redactedFields = map[protoreflect.Name]bool{
+	"script": true,
+}
+
+type requestLoggerKey struct{}
+
+type requestIdKey struct{}
+
+// 获取LoggingInterceptor为当前请求分配的请求id
+func RequestIdFromContext(ctx context.Context) string {
+	requestId, _ := ctx.Value(requestIdKey{}).(string)
+	return requestId
+}
+
+// 获取当前请求的日志，包含请求id和方法名
+func LoggerFromContext(ctx context.Context, logger *logrus.Logger) *logrus.Entry {
+	if entry, ok := ctx.Value(requestLoggerKey{}).(*logrus.Entry); ok {
+		return entry
+	}
+	return logrus.NewEntry(logger)
+}
+
+// 为每个请求分配请求id，并在请求结束后记录方法、调用方、耗时、状态码和错误原因
+func LoggingInterceptor(logger *logrus.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		requestId := incomingRequestId(ctx)
+		grpc.SetHeader(ctx, metadata.Pairs(RequestIdMetadataKey, requestId))
+
+		fields := logrus.Fields{
+			"request_id": requestId,
+			"method":     info.FullMethod,
+		}
+		if p, ok := peer.FromContext(ctx); ok {
+			fields["peer"] = p.Addr.String()
+		}
+		entry := logger.WithFields(fields)
+		ctx = context.WithValue(ctx, requestLoggerKey{}, entry)
+		ctx = context.WithValue(ctx, requestIdKey{}, requestId)
+
+		resp, err := handler(ctx, req)
+
+		code := status.Code(err)
+		entry = entry.WithFields(logrus.Fields{
+			"duration_ms": time.Since(start).Milliseconds(),
+			"code":        code.String(),
+		})
+		if message, ok := req.(proto.Message); ok {
+			entry = entry.WithField("request", RedactedMessage(message))
+		}
+		if err != nil {
+			entry.WithFields(logrus.Fields{
+				"reason": ErrorReason(err),
+				"error":  status.Convert(err).Message(),
+			}).Log(errorLogLevel(code), "Request failed")
+		} else {
+			entry.Info("Request handled")
+		}
+		return resp, err
+	}
+}
+
+// 调用方没有传入请求id时生成一个随机id
+func incomingRequestId(ctx context.Context) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(RequestIdMetadataKey); len(values) != 0 && values[0] != "" {
+			return values[0]
+		}
+	}
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// 服务端错误记为Error，参数错误、资源不存在等调用方的问题记为Warn
+func errorLogLevel(code codes.Code) logrus.Level {
+	switch code {
+	case codes.Internal, codes.Unknown, codes.Unavailable, codes.DataLoss:
+		return logrus.ErrorLevel
+	default:
+		return logrus.WarnLevel
+	}
+}
+
+// 将请求转换为便于记录的文本，隐去脚本等敏感字段，并截断过长的字符串
+func RedactedMessage(message proto.Message) string {
+	return prototext.MarshalOptions{}.Format(redactedClone(message))
+}
+
+// 复制请求并隐去敏感字段，不修改原请求
+func redactedClone(message proto.Message) proto.Message {
+	message = proto.Clone(message)
+	redactMessage(message.ProtoReflect())
+	return message
+}
+
+func redactMessage(message protoreflect.Message) {
+	message.Range(func(field protoreflect.FieldDescriptor, value protoreflect.Value) bool {
+		switch {
+		case field.IsList() && field.Kind() == protoreflect.StringKind:
+			list := value.List()
+			for i := 0; i < list.Len(); i++ {
+				list.Set(i, protoreflect.ValueOfString(redactString(field, list.Get(i).String())))
+			}
+		case field.IsList() && field.Message() != nil:
+			list := value.List()
+			for i := 0; i < list.Len(); i++ {
+				redactMessage(list.Get(i).Message())
+			}
+		case field.IsMap():
+			// 请求中的map只包含简单的键值，不需要处理
+		case field.Kind() == protoreflect.StringKind:
+			message.Set(field, protoreflect.ValueOfString(redactString(field, value.String())))
+		case field.Message() != nil:
+			redactMessage(value.Message())
+		}
+		return true
+	})
+}
+
+func redactString(field protoreflect.FieldDescriptor, value string) string {
+	if redactedFields[field.Name()] {
+		return fmt.Sprintf("<redacted %d bytes>", len(value))
+	}
+	if len(value) > maxLoggedStringLength {
+		return strings.ToValidUTF8(value[:maxLoggedStringLength], "") + "...<truncated>"
+	}
+	return value
+}
@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	protos "scow-crane-adapter/gen/go"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestRedactedMessage(t *testing.T) {
+	path := "/home/demo/job.sh"
+	request := &protos.SubmitScriptAsJobRequest{
+		UserId:             "demo",
+		Script:             "#!/bin/bash\necho secret",
+		ScriptFileFullPath: &path,
+	}
+
+	text := RedactedMessage(request)
+
+	assert.Contains(t, text, "<redacted 23 bytes>")
+	assert.NotContains(t, text, "secret")
+	assert.Contains(t, text, "demo")
+	// 原请求不会被修改
+	assert.Equal(t, "#!/bin/bash\necho secret", request.Script)
+}
+
+func TestRedactedMessageTruncatesLongStrings(t *testing.T) {
+	request := &protos.GetJobsRequest{Fields: []string{strings.Repeat("a", 1000)}}
+
+	text := RedactedMessage(request)
+
+	assert.Contains(t, text, "...<truncated>")
+	assert.Less(t, len(text), 400)
+}
+
+func TestLoggingInterceptor(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	interceptor := LoggingInterceptor(logger)
+	info := &grpc.UnaryServerInfo{FullMethod: "/scow.scheduler_adapter.JobService/SubmitScriptAsJob"}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(RequestIdMetadataKey, "req-1"))
+
+	var handlerRequestId interface{}
+	interceptor(ctx, &protos.SubmitScriptAsJobRequest{Script: "echo secret"}, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		handlerRequestId = LoggerFromContext(ctx, logger).Data["request_id"]
+		return nil, RichError(codes.Unavailable, "CRANE_CALL_FAILED", "connection refused")
+	})
+
+	assert.Equal(t, "req-1", handlerRequestId)
+	entry := hook.LastEntry()
+	if assert.NotNil(t, entry) {
+		assert.Equal(t, logrus.ErrorLevel, entry.Level)
+		assert.Equal(t, "req-1", entry.Data["request_id"])
+		assert.Equal(t, info.FullMethod, entry.Data["method"])
+		assert.Equal(t, "Unavailable", entry.Data["code"])
+		assert.Equal(t, "CRANE_CALL_FAILED", entry.Data["reason"])
+		assert.NotContains(t, entry.Data["request"], "secret")
+	}
+}
+
+func TestLoggingInterceptorGeneratesRequestId(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	info := &grpc.UnaryServerInfo{FullMethod: "/scow.scheduler_adapter.VersionService/GetVersion"}
+
+	LoggingInterceptor(logger)(context.Background(), &protos.GetVersionRequest{}, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return &protos.GetVersionResponse{}, nil
+	})
+
+	entry := hook.LastEntry()
+	if assert.NotNil(t, entry) {
+		assert.Equal(t, logrus.InfoLevel, entry.Level)
+		assert.Len(t, entry.Data["request_id"], 16)
+		assert.Equal(t, "OK", entry.Data["code"])
+	}
+}
@@ -0,0 +1,125 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os/exec"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+const metricsNamespace = "scow_crane_adapter"
+
+// 适配器的Prometheus指标，通过ServeMetrics暴露
+var (
+	metricsRegistry = prometheus.NewRegistry()
+
+	rpcRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "rpc_requests_total",
+		Help:      "Number of SCOW RPCs handled, by method and gRPC status code.",
+	}, []string{"method", "code"})
+	rpcDurationSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "rpc_duration_seconds",
+		Help:      "Latency of SCOW RPCs, by method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method"})
+	rpcErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "rpc_errors_total",
+		Help:      "Number of failed SCOW RPCs, by method and ErrorInfo reason.",
+	}, []string{"method", "reason"})
+	craneCallDurationSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "cranectld_call_duration_seconds",
+		Help:      "Latency of calls to CraneCtld, by method and gRPC status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "code"})
+	cbatchDurationSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "cbatch_duration_seconds",
+		Help:      "Duration of job submissions through cbatch, by exit status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"exit_status"})
+	tlsReloadFailuresTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "tls_reload_failures_total",
+		Help:      "Number of failed reloads of the rotated server TLS certificate.",
+	})
+)
+
+func init() {
+	metricsRegistry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		rpcRequestsTotal,
+		rpcDurationSeconds,
+		rpcErrorsTotal,
+		craneCallDurationSeconds,
+		cbatchDurationSeconds,
+		tlsReloadFailuresTotal,
+	)
+}
+
+// 获取RichError中的ErrorInfo.Reason，没有ErrorInfo时返回UNKNOWN
+func ErrorReason(err error) string {
+	for _, detail := range status.Convert(err).Details() {
+		if errInfo, ok := detail.(*errdetails.ErrorInfo); ok {
+			return errInfo.GetReason()
+		}
+	}
+	return "UNKNOWN"
+}
+
+// 统计SCOW RPC的请求数、耗时和错误原因
+func MetricsInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		rpcDurationSeconds.WithLabelValues(info.FullMethod).Observe(time.Since(start).Seconds())
+		rpcRequestsTotal.WithLabelValues(info.FullMethod, status.Code(err).String()).Inc()
+		if err != nil {
+			rpcErrorsTotal.WithLabelValues(info.FullMethod, ErrorReason(err)).Inc()
+		}
+		return resp, err
+	}
+}
+
+// 统计对CraneCtld调用的耗时
+func CraneCtldMetricsInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		craneCallDurationSeconds.WithLabelValues(method, status.Code(err).String()).Observe(time.Since(start).Seconds())
+		return err
+	}
+}
+
+// 记录一次cbatch提交的耗时和退出状态
+func observeCbatch(start time.Time, err error) {
+	exitStatus := "0"
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		exitStatus = strconv.Itoa(exitErr.ExitCode())
+	} else if err != nil {
+		// 命令没有正常退出，例如无法启动或请求取消后被终止
+		exitStatus = "error"
+	}
+	cbatchDurationSeconds.WithLabelValues(exitStatus).Observe(time.Since(start).Seconds())
+}
+
+// 在address上提供/metrics接口，阻塞直到监听失败
+func ServeMetrics(address string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{}))
+	return http.ListenAndServe(address, mux)
+}
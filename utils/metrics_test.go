@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+func TestErrorReason(t *testing.T) {
+	assert.Equal(t, "CRANE_CALL_FAILED", ErrorReason(RichError(codes.Unavailable, "CRANE_CALL_FAILED", "connection refused")))
+	assert.Equal(t, "UNKNOWN", ErrorReason(errors.New("plain error")))
+}
+
+// 指标注册在全局registry上，测试只断言本次调用带来的增量，保证可以重复运行
+func TestMetricsInterceptor(t *testing.T) {
+	const method = "/scow.scheduler_adapter.JobService/TestMetricsInterceptor"
+	info := &grpc.UnaryServerInfo{FullMethod: method}
+	interceptor := MetricsInterceptor()
+	okBefore := testutil.ToFloat64(rpcRequestsTotal.WithLabelValues(method, "OK"))
+	unavailableBefore := testutil.ToFloat64(rpcRequestsTotal.WithLabelValues(method, "Unavailable"))
+	errorsBefore := testutil.ToFloat64(rpcErrorsTotal.WithLabelValues(method, "CRANE_CALL_FAILED"))
+
+	interceptor(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, nil
+	})
+	interceptor(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, RichError(codes.Unavailable, "CRANE_CALL_FAILED", "connection refused")
+	})
+
+	assert.Equal(t, okBefore+1, testutil.ToFloat64(rpcRequestsTotal.WithLabelValues(method, "OK")))
+	assert.Equal(t, unavailableBefore+1, testutil.ToFloat64(rpcRequestsTotal.WithLabelValues(method, "Unavailable")))
+	assert.Equal(t, errorsBefore+1, testutil.ToFloat64(rpcErrorsTotal.WithLabelValues(method, "CRANE_CALL_FAILED")))
+}
+
+// 直方图中某个标签的样本数
+func histogramCount(t *testing.T, histogram *prometheus.HistogramVec, labels ...string) uint64 {
+	metric := &dto.Metric{}
+	if err := histogram.WithLabelValues(labels...).(prometheus.Metric).Write(metric); err != nil {
+		t.Fatalf("read histogram failed: %v", err)
+	}
+	return metric.GetHistogram().GetSampleCount()
+}
+
+func TestObserveCbatch(t *testing.T) {
+	failedBefore := histogramCount(t, cbatchDurationSeconds, "3")
+	succeededBefore := histogramCount(t, cbatchDurationSeconds, "0")
+
+	_, err := runCommandContext(context.Background(), "exit 3")
+	observeCbatch(time.Now(), err)
+	observeCbatch(time.Now(), nil)
+
+	assert.Equal(t, failedBefore+1, histogramCount(t, cbatchDurationSeconds, "3"))
+	assert.Equal(t, succeededBefore+1, histogramCount(t, cbatchDurationSeconds, "0"))
+}
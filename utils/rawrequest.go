@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"context"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/encoding/proto"
+	"google.golang.org/grpc/stats"
+)
+
+// HMAC签名覆盖调用方发送的请求字节，不同语言的protobuf实现编码结果不一定相同，不能在服务端重新编码
+// 编解码器在解码时记录原始字节，StatsHandler在收到消息时把原始字节放入当前请求的context
+type rawRequestKey struct{}
+
+// 当前请求收到的原始字节
+type rawRequest struct {
+	mu   sync.Mutex
+	data []byte
+	ok   bool
+}
+
+// 已解码但还没有交给StatsHandler的消息的原始字节，键为解码得到的消息
+var pendingRawRequests sync.Map
+
+// 解码时记录原始字节的protobuf编解码器
+type rawRequestCodec struct {
+	encoding.Codec
+}
+
+func (c rawRequestCodec) Unmarshal(data []byte, v interface{}) error {
+	if err := c.Codec.Unmarshal(data, v); err != nil {
+		return err
+	}
+	pendingRawRequests.Store(v, append([]byte(nil), data...))
+	return nil
+}
+
+// 把编解码器记录的原始字节移到当前请求的context中
+type rawRequestStatsHandler struct{}
+
+func (rawRequestStatsHandler) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return context.WithValue(ctx, rawRequestKey{}, &rawRequest{})
+}
+
+func (rawRequestStatsHandler) HandleRPC(ctx context.Context, s stats.RPCStats) {
+	payload, ok := s.(*stats.InPayload)
+	if !ok || payload.IsClient() {
+		return
+	}
+	data, ok := pendingRawRequests.LoadAndDelete(payload.Payload)
+	if !ok {
+		return
+	}
+	if request, ok := ctx.Value(rawRequestKey{}).(*rawRequest); ok {
+		request.mu.Lock()
+		request.data, request.ok = data.([]byte), true
+		request.mu.Unlock()
+	}
+}
+
+func (rawRequestStatsHandler) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (rawRequestStatsHandler) HandleConn(context.Context, stats.ConnStats) {}
+
+// RawRequestServerOptions 返回保留请求原始字节的服务端选项，启用HMAC签名认证时需要添加
+func RawRequestServerOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.ForceServerCodec(rawRequestCodec{encoding.GetCodec(proto.Name)}),
+		grpc.StatsHandler(rawRequestStatsHandler{}),
+	}
+}
+
+// 获取当前请求收到的原始字节，没有使用RawRequestServerOptions时ok为false
+func rawRequestFromContext(ctx context.Context) (data []byte, ok bool) {
+	request, found := ctx.Value(rawRequestKey{}).(*rawRequest)
+	if !found {
+		return nil, false
+	}
+	request.mu.Lock()
+	defer request.mu.Unlock()
+	return request.data, request.ok
+}
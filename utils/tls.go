@@ -0,0 +1,133 @@
+package utils
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// 证书文件更新后自动重新加载的服务端TLS配置，证书轮换时不需要重启适配器
+type certReloader struct {
+	certFile     string
+	keyFile      string
+	clientCaFile string
+	logger       *logrus.Logger
+
+	mu            sync.Mutex
+	modTime       time.Time // 已加载的证书文件中最新的修改时间
+	failedModTime time.Time // 最近一次重新加载失败时证书文件的修改时间，文件再次更新前不重试
+	cert          *tls.Certificate
+	clientCAs     *x509.CertPool
+}
+
+// 根据配置创建适配器gRPC服务的TLS配置，配置了ClientCaFilePath时要求并校验客户端证书
+// 证书更新后重新加载失败时记录日志，并继续使用之前的证书
+func NewServerTLSConfig(c TlsConfig, logger *logrus.Logger) (*tls.Config, error) {
+	reloader := &certReloader{
+		certFile:     c.CertFilePath,
+		keyFile:      c.KeyFilePath,
+		clientCaFile: c.ClientCaFilePath,
+		logger:       logger,
+	}
+	if err := reloader.reload(); err != nil {
+		return nil, err
+	}
+	config := baseServerTLSConfig()
+	config.GetConfigForClient = reloader.getConfigForClient
+	return config, nil
+}
+
+// 每次握手使用的TLS配置的公共部分
+// GetConfigForClient返回的配置会替换外层配置，credentials.NewTLS只在外层配置中添加h2，这里需要自己设置ALPN
+func baseServerTLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: []string{"h2"},
+	}
+}
+
+func (r *certReloader) files() []string {
+	files := []string{r.certFile, r.keyFile}
+	if r.clientCaFile != "" {
+		files = append(files, r.clientCaFile)
+	}
+	return files
+}
+
+// 获取证书文件中最新的修改时间
+func (r *certReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, file := range r.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// 重新读取证书、私钥和客户端CA
+func (r *certReloader) reload() error {
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return fmt.Errorf("stat tls files: %w", err)
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("load server certificate: %w", err)
+	}
+	var clientCAs *x509.CertPool
+	if r.clientCaFile != "" {
+		caPem, err := ioutil.ReadFile(r.clientCaFile)
+		if err != nil {
+			return fmt.Errorf("read client ca: %w", err)
+		}
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(caPem) {
+			return fmt.Errorf("no certificate found in client ca %s", r.clientCaFile)
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cert = &cert
+	r.clientCAs = clientCAs
+	r.modTime = modTime
+	return nil
+}
+
+// 每次握手时检查证书文件是否有更新，重新加载失败时继续使用之前的证书
+func (r *certReloader) getConfigForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	if modTime, err := r.latestModTime(); err == nil {
+		r.mu.Lock()
+		changed := modTime.After(r.modTime) && !modTime.Equal(r.failedModTime)
+		r.mu.Unlock()
+		if changed {
+			if err := r.reload(); err != nil {
+				r.mu.Lock()
+				r.failedModTime = modTime
+				r.mu.Unlock()
+				tlsReloadFailuresTotal.Inc()
+				r.logger.WithError(err).Error("Failed to reload tls certificate, keep using the previous one")
+			}
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	config := baseServerTLSConfig()
+	config.Certificates = []tls.Certificate{*r.cert}
+	if r.clientCAs != nil {
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+		config.ClientCAs = r.clientCAs
+	}
+	return config, nil
+}
@@ -0,0 +1,248 @@
+package utils
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+)
+
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPem []byte
+	keyPem  []byte
+}
+
+// 生成测试证书，parent为nil时生成自签名的CA证书
+func newTestCert(t *testing.T, commonName string, parent *testCert) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key failed: %v", err)
+	}
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"localhost", commonName},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	signerCert, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+	} else {
+		signerCert, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signerCert, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatalf("create certificate failed: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	keyDer, _ := x509.MarshalECPrivateKey(key)
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		certPem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPem:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}),
+	}
+}
+
+func (c *testCert) tlsCertificate(t *testing.T) tls.Certificate {
+	t.Helper()
+	cert, err := tls.X509KeyPair(c.certPem, c.keyPem)
+	if err != nil {
+		t.Fatalf("load key pair failed: %v", err)
+	}
+	return cert
+}
+
+// 写入服务端证书和私钥，并把修改时间设置为modTime
+func writeServerCert(t *testing.T, dir string, cert *testCert, modTime time.Time) TlsConfig {
+	t.Helper()
+	c := TlsConfig{
+		Enabled:      true,
+		CertFilePath: filepath.Join(dir, "server.crt"),
+		KeyFilePath:  filepath.Join(dir, "server.key"),
+	}
+	for path, content := range map[string][]byte{c.CertFilePath: cert.certPem, c.KeyFilePath: cert.keyPem} {
+		if err := os.WriteFile(path, content, 0600); err != nil {
+			t.Fatalf("write %s failed: %v", path, err)
+		}
+		os.Chtimes(path, modTime, modTime)
+	}
+	return c
+}
+
+// 启动TLS服务端，返回监听地址
+func serveTLS(t *testing.T, config *tls.Config) string {
+	t.Helper()
+	lis, err := tls.Listen("tcp", "127.0.0.1:0", config)
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	t.Cleanup(func() { lis.Close() })
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				conn.(*tls.Conn).Handshake()
+				conn.Close()
+			}()
+		}
+	}()
+	return lis.Addr().String()
+}
+
+// 握手并返回服务端证书的CommonName
+func handshake(addr string, clientConfig *tls.Config) (string, error) {
+	conn, err := tls.Dial("tcp", addr, clientConfig)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	return conn.ConnectionState().PeerCertificates[0].Subject.CommonName, nil
+}
+
+func TestServerTLSConfigReloadsRotatedCertificate(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, "ca", nil)
+	c := writeServerCert(t, dir, newTestCert(t, "server-1", ca), time.Now().Add(-time.Minute))
+	serverConfig, err := NewServerTLSConfig(c, logrus.New())
+	if err != nil {
+		t.Fatalf("NewServerTLSConfig failed: %v", err)
+	}
+	addr := serveTLS(t, serverConfig)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	clientConfig := &tls.Config{RootCAs: roots, ServerName: "localhost"}
+
+	name, err := handshake(addr, clientConfig)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "server-1", name)
+	}
+
+	writeServerCert(t, dir, newTestCert(t, "server-2", ca), time.Now())
+	name, err = handshake(addr, clientConfig)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "server-2", name)
+	}
+}
+
+func TestServerTLSConfigKeepsCertificateWhenReloadFails(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, "ca", nil)
+	c := writeServerCert(t, dir, newTestCert(t, "server-1", ca), time.Now().Add(-time.Minute))
+	logger, hook := test.NewNullLogger()
+	serverConfig, err := NewServerTLSConfig(c, logger)
+	if err != nil {
+		t.Fatalf("NewServerTLSConfig failed: %v", err)
+	}
+	addr := serveTLS(t, serverConfig)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	clientConfig := &tls.Config{RootCAs: roots, ServerName: "localhost"}
+	failuresBefore := testutil.ToFloat64(tlsReloadFailuresTotal)
+
+	// 轮换成无效的证书，重新加载失败后继续使用之前的证书
+	invalid := &testCert{certPem: []byte("invalid certificate"), keyPem: []byte("invalid key")}
+	writeServerCert(t, dir, invalid, time.Now())
+	for i := 0; i < 2; i++ {
+		name, err := handshake(addr, clientConfig)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "server-1", name)
+		}
+	}
+
+	// 同一次更新只记录一次失败
+	assert.Equal(t, failuresBefore+1, testutil.ToFloat64(tlsReloadFailuresTotal))
+	if assert.Len(t, hook.AllEntries(), 1) {
+		assert.Equal(t, logrus.ErrorLevel, hook.LastEntry().Level)
+		assert.Contains(t, hook.LastEntry().Data, logrus.ErrorKey)
+	}
+
+	// 证书修复后重新加载成功
+	writeServerCert(t, dir, newTestCert(t, "server-2", ca), time.Now().Add(time.Minute))
+	name, err := handshake(addr, clientConfig)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "server-2", name)
+	}
+}
+
+func TestServerTLSConfigNegotiatesHTTP2(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, "ca", nil)
+	c := writeServerCert(t, dir, newTestCert(t, "server", ca), time.Now())
+	serverConfig, err := NewServerTLSConfig(c, logrus.New())
+	if err != nil {
+		t.Fatalf("NewServerTLSConfig failed: %v", err)
+	}
+	addr := serveTLS(t, serverConfig)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	// grpc-js等客户端要求通过ALPN协商h2
+	conn, err := tls.Dial("tcp", addr, &tls.Config{RootCAs: roots, ServerName: "localhost", NextProtos: []string{"h2"}})
+	if err != nil {
+		t.Fatalf("handshake failed: %v", err)
+	}
+	defer conn.Close()
+	assert.Equal(t, "h2", conn.ConnectionState().NegotiatedProtocol)
+}
+
+func TestServerTLSConfigRequiresClientCertificate(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, "ca", nil)
+	c := writeServerCert(t, dir, newTestCert(t, "server", ca), time.Now())
+	c.ClientCaFilePath = filepath.Join(dir, "client-ca.crt")
+	if err := os.WriteFile(c.ClientCaFilePath, ca.certPem, 0600); err != nil {
+		t.Fatalf("write client ca failed: %v", err)
+	}
+	serverConfig, err := NewServerTLSConfig(c, logrus.New())
+	if err != nil {
+		t.Fatalf("NewServerTLSConfig failed: %v", err)
+	}
+	addr := serveTLS(t, serverConfig)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	client := newTestCert(t, "scow", ca)
+	_, err = handshake(addr, &tls.Config{RootCAs: roots, ServerName: "localhost", Certificates: []tls.Certificate{client.tlsCertificate(t)}})
+	assert.NoError(t, err)
+
+	// TLS 1.3中客户端证书在握手完成后才被校验，需要读取一次才能拿到服务端的拒绝
+	conn, err := tls.Dial("tcp", addr, &tls.Config{RootCAs: roots, ServerName: "localhost"})
+	if err == nil {
+		_, err = conn.Read(make([]byte, 1))
+		conn.Close()
+	}
+	assert.Error(t, err)
+}
+
+func TestNewServerTLSConfigMissingFiles(t *testing.T) {
+	_, err := NewServerTLSConfig(TlsConfig{Enabled: true, CertFilePath: "/nonexistent/server.crt", KeyFilePath: "/nonexistent/server.key"}, logrus.New())
+
+	assert.Error(t, err)
+}
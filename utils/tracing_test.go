@@ -0,0 +1,103 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// 使用内存中的exporter记录span，测试结束后恢复全局的TracerProvider
+func recordSpans(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	previousProvider, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+	return exporter
+}
+
+func TestInitTracingFileExporter(t *testing.T) {
+	previousProvider := otel.GetTracerProvider()
+	defer otel.SetTracerProvider(previousProvider)
+	path := filepath.Join(t.TempDir(), "trace.json")
+
+	shutdown, err := InitTracing(TracingConfig{Enabled: true, Exporter: TracingExporterFile, FilePath: path})
+	if err != nil {
+		t.Fatalf("InitTracing failed: %v", err)
+	}
+	_, span := startCommandSpan(context.Background(), "LocalSubmitJob")
+	endCommandSpan(span, nil)
+	shutdown(context.Background())
+
+	content, _ := ioutil.ReadFile(path)
+	assert.Contains(t, string(content), `"Name":"LocalSubmitJob"`)
+}
+
+func TestInitTracingUnknownExporter(t *testing.T) {
+	_, err := InitTracing(TracingConfig{Enabled: true, Exporter: "jaeger"})
+
+	assert.Error(t, err)
+}
+
+func TestCommandSpan(t *testing.T) {
+	exporter := recordSpans(t)
+
+	_, span := startCommandSpan(context.Background(), "LocalSubmitJob")
+	endCommandSpan(span, errors.New("exit status 1"))
+
+	spans := exporter.GetSpans()
+	if assert.Len(t, spans, 1) {
+		assert.Equal(t, "LocalSubmitJob", spans[0].Name)
+		assert.Len(t, spans[0].Events, 1) // 记录的错误
+	}
+}
+
+func TestTracingInterceptorPropagatesContext(t *testing.T) {
+	exporter := recordSpans(t)
+
+	lis := bufconn.Listen(1024 * 1024)
+	s := grpc.NewServer(grpc.ChainUnaryInterceptor(TracingInterceptor()))
+	healthpb.RegisterHealthServer(s, health.NewServer())
+	go s.Serve(lis)
+	defer s.Stop()
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("did not connect: %v", err)
+	}
+	defer conn.Close()
+
+	// SCOW传来的trace context
+	traceId := "4bf92f3577b34da6a3ce929d0e0e4736"
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "traceparent", "00-"+traceId+"-00f067aa0ba902b7-01")
+	healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+
+	spans := exporter.GetSpans()
+	if assert.Len(t, spans, 1) {
+		assert.Equal(t, "grpc.health.v1.Health/Check", spans[0].Name)
+		assert.Equal(t, traceId, spans[0].SpanContext.TraceID().String())
+		assert.Equal(t, trace.SpanKindServer, spans[0].SpanKind)
+	}
+}
@@ -4,69 +4,36 @@ import (
 	"bytes"
 	"context"
 	"fmt"
-	"io/ioutil"
-	"log"
 	"os"
 	"os/exec"
 	"os/user"
-	"reflect"
-	"sort"
+	"regexp"
 	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	craneProtos "scow-crane-adapter/gen/crane"
-	protos "scow-crane-adapter/gen/go"
 
+	"go.opentelemetry.io/otel/attribute"
 	"google.golang.org/genproto/googleapis/rpc/errdetails"
-	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/status"
-	"gopkg.in/yaml.v2"
+	"google.golang.org/protobuf/types/known/durationpb"
 )
 
-type Config struct {
-	ClusterName         string `yaml:"ClusterName"`
-	ControlMachine      string `yaml:"ControlMachine"`
-	CraneCtldListenPort string `yaml:"CraneCtldListenPort"`
-
-	UseTls             bool        `yaml:"UseTls"`
-	ServerCertFilePath string      `yaml:"ServerCertFilePath"`
-	ServerKeyFilePath  string      `yaml:"ServerKeyFilePath"`
-	CaCertFilePath     string      `yaml:"CaCertFilePath"`
-	DomainSuffix       string      `yaml:"DomainSuffix"`
-	Partitions         []Partition `yaml:"Partitions"`
-}
-
-type Partition struct {
-	Name  string `yaml:"name"`
-	Nodes string `yaml:"nodes"`
-}
-
-var DefaultConfigPath = "/etc/crane/config.yaml"
-
-// 解析crane配置文件
-func ParseConfig(configFilePath string) *Config {
-	confFile, err := ioutil.ReadFile(configFilePath)
-	if err != nil {
-		log.Fatal(err)
-	}
-	config := &Config{}
-	err = yaml.Unmarshal(confFile, config)
-	if err != nil {
-		log.Fatal(err)
-	}
-	return config
-}
+var cbatchJobIdRegexp = regexp.MustCompile(`Job id allocated:\s*(\d+)`)
 
 // 通过os/user包去获取用户的uid
 func GetUidByUserName(userName string) (int, error) {
 	u, err := user.Lookup(userName)
 	if err != nil {
-		fmt.Printf("Failed to lookup user: %s\n", err)
-		return 0, err
+		return 0, fmt.Errorf("failed to lookup user %s: %w", userName, err)
+	}
+	uid, err := strconv.Atoi(u.Uid)
+	if err != nil {
+		return 0, fmt.Errorf("invalid uid %q of user %s", u.Uid, userName)
 	}
-	uid, _ := strconv.Atoi(u.Uid)
 	return uid, nil
 }
 
@@ -81,26 +48,21 @@ func RichError(code codes.Code, reason string, message string) error {
 }
 
 // 获取系统中Qos列表
-func GetQos() ([]string, error) {
+func GetQos(ctx context.Context, stubCraneCtld CraneCtldClient) ([]string, error) {
 	var (
 		Qoslist []string
 	)
-	config := ParseConfig(DefaultConfigPath)
-	serverAddr := fmt.Sprintf("%s:%s", config.ControlMachine, config.CraneCtldListenPort)
-	conn, err := grpc.Dial(serverAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
-	if err != nil {
-		log.Fatal("Cannot connect to CraneCtld: " + err.Error())
-	}
-	defer conn.Close()
-	stubCraneCtld := craneProtos.NewCraneCtldClient(conn)
 	request := &craneProtos.QueryEntityInfoRequest{
 		Uid:        uint32(os.Getuid()),
 		EntityType: craneProtos.EntityType_Qos,
 	}
-	response, err := stubCraneCtld.QueryEntityInfo(context.Background(), request)
+	response, err := stubCraneCtld.QueryEntityInfo(ctx, request)
 	if err != nil {
 		return []string{}, err
 	}
+	if !response.GetOk() {
+		return []string{}, CraneReplyError(response.GetReason())
+	}
 	Qos := response.GetQosList()
 	for _, value := range Qos {
 		Qoslist = append(Qoslist, value.GetName())
@@ -154,85 +116,108 @@ func RemoveValue(list []string, value string) []string {
 	return result
 }
 
-func sortByKey(list []*protos.JobInfo, fieldName string, sortOrder string) bool {
-	if sortOrder == "ASC" {
-		sort.Slice(list, func(i, j int) bool {
-			fieldValueI := reflect.ValueOf(list[i]).Elem().FieldByName(fieldName)
-			fieldValueJ := reflect.ValueOf(list[j]).Elem().FieldByName(fieldName)
-			switch fieldValueI.Kind() {
-			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-				return fieldValueI.Int() < fieldValueJ.Int()
-			case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-				return fieldValueI.Uint() > fieldValueJ.Uint()
-			case reflect.Float32, reflect.Float64:
-				return fieldValueI.Float() < fieldValueJ.Float()
-			case reflect.String:
-				return fieldValueI.String() < fieldValueJ.String()
-			default:
-				return false
-			}
-		})
-	} else if sortOrder == "DESC" {
-		sort.Slice(list, func(i, j int) bool {
-			fieldValueI := reflect.ValueOf(list[i]).Elem().FieldByName(fieldName)
-			fieldValueJ := reflect.ValueOf(list[j]).Elem().FieldByName(fieldName)
-			switch fieldValueI.Kind() {
-			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-				return fieldValueI.Int() > fieldValueJ.Int()
-			case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-				return fieldValueI.Uint() > fieldValueJ.Uint()
-			case reflect.Float32, reflect.Float64:
-				return fieldValueI.Float() > fieldValueJ.Float()
-			case reflect.String:
-				return fieldValueI.String() > fieldValueJ.String()
-			default:
-				return false
-			}
-		})
+// 计算分页后的下标范围[start, end)，SCOW的页码从1开始，pageSize为0时不分页
+func PageRange(total int, page uint64, pageSize uint64) (int, int) {
+	if pageSize == 0 {
+		return 0, total
 	}
-	return true
-}
-
-func SortJobInfo(sortKey string, sortOrder string, jobInfo []*protos.JobInfo) []*protos.JobInfo {
-	sortByKey(jobInfo, sortKey, sortOrder)
-	return jobInfo
+	if page == 0 {
+		page = 1
+	}
+	start := (page - 1) * pageSize
+	if start/pageSize != page-1 || start >= uint64(total) {
+		// 超出范围（或乘法溢出）时返回空页
+		return total, total
+	}
+	end := start + pageSize
+	if end < start || end > uint64(total) {
+		end = uint64(total)
+	}
+	return int(start), int(end)
 }
 
-// 本地提交cbatch作业函数
-func LocalSubmitJob(scriptString string, username string) (string, error) {
+// 本地提交cbatch作业函数，ctx结束时终止cbatch
+func LocalSubmitJob(ctx context.Context, scriptString string, username string) (string, error) {
 	// 提交作业命令行
 	cmdLine := fmt.Sprintf("su - %s -c 'cbatch %s'", username, scriptString)
-	cmd := exec.Command("bash", "-c", cmdLine)
-
-	// 创建一个 bytes.Buffer 用于捕获输出
-	var output bytes.Buffer
-	cmd.Stdout = &output
-	cmd.Stderr = &output
+	ctx, span := startCommandSpan(ctx, "LocalSubmitJob", attribute.String("user", username), attribute.String("script_path", scriptString))
+	start := time.Now()
+	output, err := runCommandContext(ctx, cmdLine)
+	observeCbatch(start, err)
+	endCommandSpan(span, err)
+	return output, err
+}
 
-	// 执行命令
-	err := cmd.Run()
+// 从cbatch的输出中解析作业id
+func ParseCbatchJobId(output string) (uint32, error) {
+	match := cbatchJobIdRegexp.FindStringSubmatch(output)
+	if match == nil {
+		return 0, fmt.Errorf("unexpected cbatch output: %s", strings.TrimSpace(output))
+	}
+	jobId, err := strconv.ParseUint(match[1], 10, 32)
 	if err != nil {
-		return output.String(), err
+		return 0, fmt.Errorf("invalid job id in cbatch output: %s", match[1])
 	}
+	return uint32(jobId), nil
+}
 
-	return output.String(), nil
+// 未指定时长限制时使用的时长，与cbatch的默认值保持一致，由CraneCtld按分区配置处理
+func InvalidDuration() *durationpb.Duration {
+	return &durationpb.Duration{Seconds: 315576000000}
 }
 
-// 简单执行shell命令函数
-func RunCommand(command string) (string, error) {
+// 执行shell命令并返回标准输出和标准错误
+// 命令在单独的进程组中运行，ctx结束时终止整个进程组，避免su等子进程继续运行
+func runCommandContext(ctx context.Context, command string) (string, error) {
 	cmd := exec.Command("bash", "-c", command)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
 
 	// 创建一个 bytes.Buffer 用于捕获输出
 	var output bytes.Buffer
 	cmd.Stdout = &output
 	cmd.Stderr = &output
 
-	// 执行命令
-	err := cmd.Run()
-
-	if err != nil {
+	if err := cmd.Start(); err != nil {
+		return "", err
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+	select {
+	case err := <-done:
 		return output.String(), err
+	case <-ctx.Done():
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		<-done
+		return output.String(), ctx.Err()
 	}
+}
 
-	return strings.TrimSpace(output.String()), nil
-}
\ No newline at end of file
+// 判断脚本中是否已经通过#CBATCH指定了工作目录
+func HasChdirDirective(script string) bool {
+	for _, line := range strings.Split(script, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[0] != "#CBATCH" {
+			continue
+		}
+		for _, field := range fields[1:] {
+			if field == "-D" || field == "--chdir" || strings.HasPrefix(field, "--chdir=") || (strings.HasPrefix(field, "-D") && len(field) > 2) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// 在脚本的解释器行之后插入一行#CBATCH指令，脚本其余内容保持不变
+func InsertDirective(script string, directive string) string {
+	if strings.HasPrefix(script, "#!") {
+		index := strings.Index(script, "\n")
+		if index == -1 {
+			return script + "\n" + directive + "\n"
+		}
+		return script[:index+1] + directive + "\n" + script[index+1:]
+	}
+	return directive + "\n" + script
+}
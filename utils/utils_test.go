@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetUidByUserName(t *testing.T) {
+	uid, err := GetUidByUserName("root")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, uid)
+
+	_, err = GetUidByUserName("no_such_user_for_test")
+	assert.Error(t, err)
+}
+
+func TestHasChdirDirective(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		chdir  bool
+	}{
+		{"short option", "#!/bin/bash\n#CBATCH -D /data\necho hello\n", true},
+		{"long option", "#!/bin/bash\n#CBATCH --chdir /data\n", true},
+		{"long option with value", "#!/bin/bash\n#CBATCH --chdir=/data\n", true},
+		{"short option with value", "#!/bin/bash\n#CBATCH -D/data\n", true},
+		{"after other options", "#CBATCH -p CPU -D /data\n", true},
+		{"no directive", "#!/bin/bash\n#CBATCH -p CPU\necho hello\n", false},
+		{"single field", "#!/bin/bash\n#CBATCH\necho hello\n", false},
+		{"not a directive", "#!/bin/bash\n# CBATCH -D /data\necho -D /data\n", false},
+		{"other option prefix", "#CBATCH --chdirx /data\n", false},
+		{"empty script", "", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.chdir, HasChdirDirective(tt.script), tt.name)
+	}
+}
+
+func TestInsertDirective(t *testing.T) {
+	const directive = "#CBATCH --chdir /data"
+	tests := []struct {
+		name   string
+		script string
+		result string
+	}{
+		{"shebang", "#!/bin/bash\necho hello\n", "#!/bin/bash\n#CBATCH --chdir /data\necho hello\n"},
+		{"shebang without trailing newline", "#!/bin/bash", "#!/bin/bash\n#CBATCH --chdir /data\n"},
+		{"no shebang", "echo hello\n", "#CBATCH --chdir /data\necho hello\n"},
+		{"existing directives", "#!/bin/bash\n#CBATCH -p CPU\n", "#!/bin/bash\n#CBATCH --chdir /data\n#CBATCH -p CPU\n"},
+		{"empty script", "", "#CBATCH --chdir /data\n"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.result, InsertDirective(tt.script, directive), tt.name)
+	}
+}
+
+func TestPageRange(t *testing.T) {
+	tests := []struct {
+		total          int
+		page, pageSize uint64
+		start, end     int
+	}{
+		{10, 0, 0, 0, 10},
+		{10, 1, 0, 0, 10},
+		{10, 1, 3, 0, 3},
+		{10, 0, 3, 0, 3},
+		{10, 2, 3, 3, 6},
+		{10, 4, 3, 9, 10},
+		{10, 5, 3, 10, 10},
+		{0, 1, 3, 0, 0},
+		{10, math.MaxUint64, 3, 10, 10},
+		{10, 2, math.MaxUint64, 10, 10},
+	}
+	for _, tt := range tests {
+		start, end := PageRange(tt.total, tt.page, tt.pageSize)
+		assert.Equal(t, tt.start, start, "%+v", tt)
+		assert.Equal(t, tt.end, end, "%+v", tt)
+	}
+}